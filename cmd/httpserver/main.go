@@ -0,0 +1,52 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Dawid-Klos/httpfromtcp/internal/request"
+	"github.com/Dawid-Klos/httpfromtcp/internal/response"
+	"github.com/Dawid-Klos/httpfromtcp/internal/server"
+)
+
+const port = 42069
+
+func main() {
+	srv, err := server.Serve(port, handler)
+	if err != nil {
+		log.Fatalf("error starting server: %v", err)
+	}
+	defer srv.Close()
+	log.Println("Server started on port", port)
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	<-sigChan
+	log.Println("Server gracefully stopped")
+}
+
+func handler(w *response.Writer, req *request.Request) {
+	statusCode := response.StatusOK
+	body := "All good, frfr\n"
+	switch req.RequestLine.Target {
+	case "/yourproblem":
+		statusCode = response.StatusBadRequest
+		body = "Your problem is not my problem\n"
+	case "/myproblem":
+		statusCode = response.StatusInternalServerError
+		body = "Woopsie, my bad\n"
+	}
+
+	err := w.WriteStatusLine(statusCode)
+	if err == nil {
+		err = w.WriteHeaders(response.GetDefaultHeaders(len(body)))
+	}
+	if err == nil {
+		_, err = w.WriteBody([]byte(body))
+	}
+	if err != nil {
+		log.Printf("error writing response: %v", err)
+	}
+}
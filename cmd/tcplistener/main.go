@@ -1,42 +1,106 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/Dawid-Klos/httpfromtcp/internal/request"
+	"github.com/Dawid-Klos/httpfromtcp/internal/response"
+	"io"
 	"log"
 	"net"
+	"os"
+	"os/signal"
+	"time"
+)
+
+const (
+	headerTimeout = 10 * time.Second
+	bodyTimeout   = 30 * time.Second
+	minBodyRate   = 1 << 10
 )
 
 func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
 	listener, err := net.Listen("tcp", ":42069")
 	if err != nil {
 		log.Fatal("error", "err", err)
 	}
 	defer listener.Close()
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
 
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
 			log.Fatal("error", "err", err)
 		}
 		fmt.Println("-> Accepted connection from", conn.RemoteAddr())
 
-		req, err := request.RequestFromReader(conn)
-		if err != nil {
-			log.Fatal("error", "err:", err)
-		}
+		reader := request.NewReader(conn)
+		reader.HeaderTimeout = headerTimeout
+		reader.BodyTimeout = bodyTimeout
+		reader.MinBodyRate = minBodyRate
+		for {
+			req, err := reader.ReadRequestContext(ctx)
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					log.Println("error reading request:", err)
+					rejectRequest(conn, err)
+				}
+				break
+			}
+
+			fmt.Println("Request line:")
+			fmt.Printf("- Method: %s\n", req.RequestLine.Method)
+			fmt.Printf("- Target: %s\n", req.RequestLine.Target)
+			fmt.Printf("- Version: %s\n", req.RequestLine.HTTPVersion)
+			fmt.Println("Headers:")
+			for key, value := range req.Headers.All() {
+				fmt.Printf("- %s: %s\n", key, value)
+			}
+			fmt.Println("Body:")
+			fmt.Printf("%s\n", string(req.Body))
 
-		fmt.Println("Request line:")
-		fmt.Printf("- Method: %s\n", req.RequestLine.Method)
-		fmt.Printf("- Target: %s\n", req.RequestLine.Target)
-		fmt.Printf("- Version: %s\n", req.RequestLine.HTTPVersion)
-		fmt.Println("Headers:")
-		for key, value := range req.Headers {
-			fmt.Printf("- %s: %s\n", key, value)
+			if !req.KeepAlive() {
+				break
+			}
 		}
-		fmt.Println("Body:")
-		fmt.Printf("%s\n", string(req.Body))
 
+		reader.Release()
+		conn.Close()
 		fmt.Println("-> Connection to", conn.RemoteAddr(), "closed")
 	}
 }
+
+// rejectRequest answers a request that could not be parsed with the status
+// code suggested by the parser.
+func rejectRequest(conn net.Conn, err error) {
+	var parseErr *request.ParseError
+	if !errors.As(err, &parseErr) {
+		return
+	}
+
+	body := []byte(parseErr.Err.Error() + "\n")
+	h := response.GetDefaultHeaders(len(body))
+	h.Set("Connection", "close")
+
+	w := response.NewWriter(conn)
+	err = w.WriteStatusLine(response.StatusCode(parseErr.StatusCode))
+	if err == nil {
+		err = w.WriteHeaders(h)
+	}
+	if err == nil {
+		_, err = w.WriteBody(body)
+	}
+	if err != nil {
+		log.Println("error writing response:", err)
+	}
+}
@@ -0,0 +1,231 @@
+// Package chunked decodes the chunked transfer coding defined in RFC 9112
+// section 7.1.
+//
+// A Decoder is fed the raw message body incrementally and hands back the chunk
+// data it contains, validating chunk sizes and extensions along the way. The
+// trailer section after the last chunk is parsed into a headers.Headers value.
+package chunked
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/Dawid-Klos/httpfromtcp/internal/headers"
+)
+
+type decoderState string
+
+const (
+	decoderStateSize     decoderState = "parsing chunk size"
+	decoderStateData     decoderState = "parsing chunk data"
+	decoderStateDataEnd  decoderState = "parsing chunk data end"
+	decoderStateTrailers decoderState = "parsing trailers"
+	decoderStateDone     decoderState = "done"
+)
+
+// maxChunkLineLength caps the chunk-size line, including any extensions, so a
+// peer cannot make the decoder buffer an unbounded amount of data.
+const maxChunkLineLength = 4096
+
+var CRLF = []byte("\r\n")
+
+var ErrMalformedChunkSize = errors.New("malformed chunk size")
+var ErrMalformedChunkExtension = errors.New("malformed chunk extension")
+var ErrMalformedChunk = errors.New("malformed chunk data")
+var ErrChunkLineTooLong = errors.New("chunk size line too long")
+var ErrDecodingInDoneState = errors.New("trying to decode data in done state")
+
+type Decoder struct {
+	// Trailers holds the trailer fields received after the last chunk.
+	Trailers headers.Headers
+
+	state     decoderState
+	remaining int
+}
+
+func NewDecoder() *Decoder {
+	return &Decoder{
+		Trailers: headers.NewHeaders(),
+		state:    decoderStateSize,
+	}
+}
+
+// Decode consumes the next element of the chunked body at the start of data:
+// a chunk-size line, chunk data, the CRLF closing a chunk or a trailer field
+// line. Chunk data is returned as a sub-slice of data. When data is too short
+// to make progress, Decode consumes nothing and the caller should retry once
+// more data is available.
+func (d *Decoder) Decode(data []byte) (n int, chunk []byte, err error) {
+	switch d.state {
+	case decoderStateSize:
+		size, n, err := parseChunkSize(data)
+		if err != nil {
+			return 0, nil, err
+		}
+		if n == 0 {
+			return 0, nil, nil
+		}
+		if size == 0 {
+			d.state = decoderStateTrailers
+		} else {
+			d.remaining = size
+			d.state = decoderStateData
+		}
+		return n, nil, nil
+	case decoderStateData:
+		n := min(len(data), d.remaining)
+		d.remaining -= n
+		if d.remaining == 0 {
+			d.state = decoderStateDataEnd
+		}
+		return n, data[:n], nil
+	case decoderStateDataEnd:
+		if len(data) < len(CRLF) {
+			return 0, nil, nil
+		}
+		if !bytes.HasPrefix(data, CRLF) {
+			return 0, nil, ErrMalformedChunk
+		}
+		d.state = decoderStateSize
+		return len(CRLF), nil, nil
+	case decoderStateTrailers:
+		n, done, err := d.Trailers.Parse(data)
+		if err != nil {
+			return 0, nil, err
+		}
+		if done {
+			d.state = decoderStateDone
+		}
+		return n, nil, nil
+	default:
+		return 0, nil, ErrDecodingInDoneState
+	}
+}
+
+// Done reports whether the last chunk and the trailer section were decoded.
+func (d *Decoder) Done() bool {
+	return d.state == decoderStateDone
+}
+
+// InTrailers reports whether the decoder is parsing the trailer section.
+func (d *Decoder) InTrailers() bool {
+	return d.state == decoderStateTrailers
+}
+
+// IsChunked reports whether chunked is the final coding in a Transfer-Encoding
+// field value.
+func IsChunked(te string) bool {
+	codings := strings.Split(te, ",")
+	last := strings.TrimSpace(codings[len(codings)-1])
+	return strings.EqualFold(last, "chunked")
+}
+
+// parseChunkSize parses a chunk-size line including optional chunk
+// extensions, which are validated and discarded. It returns 0 bytes read
+// when the line is not yet complete.
+func parseChunkSize(data []byte) (int, int, error) {
+	idx := bytes.Index(data, CRLF)
+	if idx == -1 {
+		if len(data) > maxChunkLineLength {
+			return 0, 0, ErrChunkLineTooLong
+		}
+		return 0, 0, nil
+	}
+	if idx > maxChunkLineLength {
+		return 0, 0, ErrChunkLineTooLong
+	}
+
+	line := data[:idx]
+	sizePart := line
+	if extIdx := bytes.IndexByte(line, ';'); extIdx != -1 {
+		if !validChunkExtensions(line[extIdx:]) {
+			return 0, 0, ErrMalformedChunkExtension
+		}
+		// BWS is only allowed before the ";" of an extension, so whitespace
+		// after a size without one is rejected below.
+		sizePart = bytes.TrimRight(line[:extIdx], " \t")
+	}
+
+	// 15 hex digits keeps the size well within an int on 64-bit platforms.
+	if len(sizePart) == 0 || len(sizePart) > 15 {
+		return 0, 0, ErrMalformedChunkSize
+	}
+	for _, c := range sizePart {
+		if !isHexDigit(c) {
+			return 0, 0, ErrMalformedChunkSize
+		}
+	}
+	size, err := strconv.ParseInt(string(sizePart), 16, 64)
+	if err != nil {
+		return 0, 0, ErrMalformedChunkSize
+	}
+
+	return int(size), idx + len(CRLF), nil
+}
+
+// validChunkExtensions validates chunk-ext as defined in RFC 9112 section
+// 7.1.1: *( BWS ";" BWS ext-name [ BWS "=" BWS ext-val ] ).
+func validChunkExtensions(b []byte) bool {
+	for len(b) > 0 {
+		b = bytes.TrimLeft(b, " \t")
+		if len(b) == 0 || b[0] != ';' {
+			return false
+		}
+		b = bytes.TrimLeft(b[1:], " \t")
+
+		nameEnd := tokenEnd(b)
+		if nameEnd == 0 {
+			return false
+		}
+		b = bytes.TrimLeft(b[nameEnd:], " \t")
+		if len(b) == 0 || b[0] != '=' {
+			continue
+		}
+		b = bytes.TrimLeft(b[1:], " \t")
+
+		if len(b) > 0 && b[0] == '"' {
+			end := quotedStringEnd(b)
+			if end == -1 {
+				return false
+			}
+			b = b[end:]
+			continue
+		}
+		valueEnd := tokenEnd(b)
+		if valueEnd == 0 {
+			return false
+		}
+		b = b[valueEnd:]
+	}
+
+	return true
+}
+
+func tokenEnd(b []byte) int {
+	for i, c := range b {
+		if !headers.IsTokenChar(c) {
+			return i
+		}
+	}
+	return len(b)
+}
+
+// quotedStringEnd returns the index just past the closing quote of the
+// quoted-string at the start of b, or -1 if it is not terminated.
+func quotedStringEnd(b []byte) int {
+	for i := 1; i < len(b); i++ {
+		switch b[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return -1
+}
+
+func isHexDigit(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
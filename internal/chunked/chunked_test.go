@@ -0,0 +1,89 @@
+package chunked
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decodeAll feeds data to a fresh decoder and returns the decoded body.
+func decodeAll(t *testing.T, data string) (*Decoder, string, error) {
+	t.Helper()
+	d := NewDecoder()
+	var body []byte
+	buf := []byte(data)
+	for !d.Done() {
+		n, chunk, err := d.Decode(buf)
+		if err != nil {
+			return d, "", err
+		}
+		if n == 0 {
+			break
+		}
+		body = append(body, chunk...)
+		buf = buf[n:]
+	}
+	return d, string(body), nil
+}
+
+func TestDecoder(t *testing.T) {
+	// Test: Chunks, extensions and trailers
+	d, body, err := decodeAll(t, "5;ext=1\r\nhello\r\n"+
+		"7 ; q=\"a;b\"\r\n world!\r\n"+
+		"0\r\n"+
+		"Checksum: abc123\r\n"+
+		"\r\n")
+	require.NoError(t, err)
+	assert.True(t, d.Done())
+	assert.Equal(t, "hello world!", body)
+	checksum, err := d.Trailers.Get("checksum")
+	require.NoError(t, err)
+	assert.Equal(t, "abc123", checksum)
+
+	// Test: Incomplete input waits for more data
+	d, body, err = decodeAll(t, "a\r\nhello")
+	require.NoError(t, err)
+	assert.False(t, d.Done())
+	assert.Equal(t, "hello", body)
+
+	// Test: Signed chunk size
+	_, _, err = decodeAll(t, "+5\r\nhello\r\n0\r\n\r\n")
+	require.ErrorIs(t, err, ErrMalformedChunkSize)
+
+	// Test: Whitespace after a chunk size without an extension
+	for _, line := range []string{"5 \r\n", "5\t\r\n", " 5\r\n"} {
+		_, _, err = decodeAll(t, line+"hello\r\n0\r\n\r\n")
+		require.ErrorIs(t, err, ErrMalformedChunkSize, line)
+	}
+
+	// Test: Whitespace before an extension
+	_, body, err = decodeAll(t, "5 ;ext\r\nhello\r\n0\r\n\r\n")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", body)
+
+	// Test: Oversized chunk size
+	_, _, err = decodeAll(t, "1000000000000000\r\n")
+	require.ErrorIs(t, err, ErrMalformedChunkSize)
+
+	// Test: Unterminated quoted extension
+	_, _, err = decodeAll(t, "5;a=\"open\r\nhello\r\n0\r\n\r\n")
+	require.ErrorIs(t, err, ErrMalformedChunkExtension)
+
+	// Test: Chunk size line too long
+	_, _, err = decodeAll(t, "5;"+string(make([]byte, maxChunkLineLength)))
+	require.ErrorIs(t, err, ErrChunkLineTooLong)
+
+	// Test: Decoding after done
+	d, _, err = decodeAll(t, "0\r\n\r\n")
+	require.NoError(t, err)
+	_, _, err = d.Decode([]byte("0\r\n"))
+	require.ErrorIs(t, err, ErrDecodingInDoneState)
+}
+
+func TestIsChunked(t *testing.T) {
+	assert.True(t, IsChunked("chunked"))
+	assert.True(t, IsChunked("gzip, Chunked"))
+	assert.False(t, IsChunked("chunked, gzip"))
+	assert.False(t, IsChunked("gzip"))
+}
@@ -0,0 +1,326 @@
+// Package client sends HTTP/1.1 requests over TCP and reads the responses.
+//
+// Requests are request.Request values written with Request.WriteTo, and
+// replies are parsed with the response package, so the client exercises the
+// same code as the server side of this module. The request target is either
+// an absolute URL ("http://host:port/path") or an origin-form path combined
+// with a Host header; either way the Host header is filled in when missing.
+// Connections are kept alive and reused through a Pool.
+package client
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Dawid-Klos/httpfromtcp/internal/headers"
+	"github.com/Dawid-Klos/httpfromtcp/internal/request"
+	"github.com/Dawid-Klos/httpfromtcp/internal/response"
+)
+
+type Client struct {
+	// Timeout bounds a whole exchange, including any redirects followed. Zero
+	// means no timeout.
+	Timeout time.Duration
+	// DialTimeout bounds establishing each connection. Zero means no timeout
+	// beyond Timeout.
+	DialTimeout time.Duration
+	// MaxRedirects is how many redirects Do follows before giving up. A
+	// negative value disables following redirects.
+	MaxRedirects int
+	// Pool keeps connections open for reuse between requests. When nil,
+	// every request uses a new connection that is closed afterwards.
+	Pool *Pool
+}
+
+var DefaultClient = &Client{
+	Timeout:      30 * time.Second,
+	DialTimeout:  10 * time.Second,
+	MaxRedirects: 10,
+	Pool:         DefaultPool,
+}
+
+var ErrMissingHost = errors.New("request has no host to connect to")
+var ErrUnsupportedScheme = errors.New("unsupported URL scheme")
+var ErrTooManyRedirects = errors.New("too many redirects")
+
+// Do sends req with DefaultClient.
+func Do(req *request.Request) (*response.Response, error) {
+	return DefaultClient.Do(req)
+}
+
+// NewRequest builds a request for an absolute http URL.
+func NewRequest(method string, rawURL string, body []byte) (*request.Request, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" {
+		return nil, ErrUnsupportedScheme
+	}
+	if u.Host == "" {
+		return nil, ErrMissingHost
+	}
+
+	return &request.Request{
+		RequestLine: request.RequestLine{
+			HTTPVersion: "1.1",
+			Version:     request.HTTP11,
+			Target:      u.String(),
+			Method:      method,
+		},
+		Headers: headers.NewHeaders(),
+		Body:    body,
+	}, nil
+}
+
+// Do sends req and returns the final response, following redirects up to
+// MaxRedirects. Interim 1xx responses are skipped. The caller's request is
+// never modified.
+func (c *Client) Do(req *request.Request) (*response.Response, error) {
+	var deadline time.Time
+	if c.Timeout > 0 {
+		deadline = time.Now().Add(c.Timeout)
+	}
+
+	for redirects := 0; ; redirects++ {
+		target, err := resolveTarget(req)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := c.send(req, target, deadline)
+		if err != nil {
+			return nil, err
+		}
+
+		if c.MaxRedirects < 0 || !isRedirect(resp.StatusLine.StatusCode) {
+			return resp, nil
+		}
+		location, err := resp.Headers.Get("Location")
+		if err != nil {
+			return resp, nil
+		}
+		if redirects >= c.MaxRedirects {
+			return nil, ErrTooManyRedirects
+		}
+
+		next, ok, err := redirectRequest(req, target.url, location, resp.StatusLine.StatusCode)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return resp, nil
+		}
+		req = next
+	}
+}
+
+func (c *Client) send(req *request.Request, target *target, deadline time.Time) (*response.Response, error) {
+	dial := func() (net.Conn, error) {
+		dialer := net.Dialer{Timeout: c.DialTimeout, Deadline: deadline}
+		return dialer.Dial("tcp", target.addr)
+	}
+
+	out := outgoingRequest(req, target)
+	if c.Pool == nil {
+		conn, err := dial()
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+
+		err = conn.SetDeadline(deadline)
+		if err != nil {
+			return nil, err
+		}
+		out.Headers.Set("Connection", "close")
+		_, err = out.WriteTo(conn)
+		if err != nil {
+			return nil, err
+		}
+		return readFinalResponse(response.NewReader(conn), req.RequestLine.Method)
+	}
+
+	for attempt := 0; ; attempt++ {
+		pc, err := c.Pool.get(target.addr, dial, deadline)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, retryable, err := pc.roundTrip(out, req.RequestLine.Method, deadline)
+		if err != nil {
+			c.Pool.discard(pc)
+			// A reused connection may have been closed by the server just
+			// before the request went out. Idempotent requests whose body
+			// can be sent again are safe to retry once on a new connection.
+			if retryable && pc.reused && attempt == 0 && canRetry(out) {
+				continue
+			}
+			return nil, err
+		}
+
+		if resp.KeepAlive() && out.KeepAlive() {
+			c.Pool.put(pc)
+		} else {
+			c.Pool.discard(pc)
+		}
+		return resp, nil
+	}
+}
+
+// canRetry reports whether sending req a second time is safe: its method is
+// idempotent and its body, if any, is held in memory.
+func canRetry(req *request.Request) bool {
+	switch req.RequestLine.Method {
+	case "GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE":
+	default:
+		return false
+	}
+	return len(req.Body) > 0 || req.BodyReader == nil
+}
+
+// readFinalResponse skips interim 1xx responses, except 101 Switching
+// Protocols which ends the HTTP exchange on the connection.
+func readFinalResponse(reader *response.Reader, method string) (*response.Response, error) {
+	for {
+		resp, err := reader.ReadResponse(method)
+		if err != nil {
+			return nil, err
+		}
+		code := resp.StatusLine.StatusCode
+		if code >= 200 || code == response.StatusSwitchingProtocols {
+			return resp, nil
+		}
+	}
+}
+
+// target is where a request is sent and how it is addressed on the wire.
+type target struct {
+	url  *url.URL
+	addr string
+}
+
+func resolveTarget(req *request.Request) (*target, error) {
+	raw := req.RequestLine.Target
+	if !strings.HasPrefix(raw, "/") && raw != "*" {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, err
+		}
+		if u.Scheme != "http" {
+			return nil, ErrUnsupportedScheme
+		}
+		if u.Host == "" {
+			return nil, ErrMissingHost
+		}
+		return &target{url: u, addr: hostPort(u)}, nil
+	}
+
+	host, err := req.Headers.Get("Host")
+	if err != nil || host == "" {
+		return nil, ErrMissingHost
+	}
+	u, err := url.Parse("http://" + host + raw)
+	if err != nil {
+		return nil, err
+	}
+	return &target{url: u, addr: hostPort(u)}, nil
+}
+
+func hostPort(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "80"
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// outgoingRequest copies req for the wire: the target is rewritten to
+// origin-form and a missing Host header is defaulted from the URL.
+func outgoingRequest(req *request.Request, target *target) *request.Request {
+	out := &request.Request{
+		RequestLine: req.RequestLine,
+		Headers:     headers.NewHeaders(),
+		Body:        req.Body,
+		BodyReader:  req.BodyReader,
+	}
+	if out.RequestLine.HTTPVersion == "" && out.RequestLine.Version == (request.Version{}) {
+		out.RequestLine.HTTPVersion = "1.1"
+		out.RequestLine.Version = request.HTTP11
+	}
+	if req.RequestLine.Target != "*" {
+		out.RequestLine.Target = target.url.RequestURI()
+	}
+
+	for name, value := range req.Headers.All() {
+		out.Headers.Add(name, value)
+	}
+	if _, err := out.Headers.Get("Host"); err != nil {
+		out.Headers.Set("Host", target.url.Host)
+	}
+	return out
+}
+
+func isRedirect(code response.StatusCode) bool {
+	switch code {
+	case response.StatusMovedPermanently, response.StatusFound, response.StatusSeeOther,
+		response.StatusTemporaryRedirect, response.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// redirectRequest builds the request that follows a redirect. 301, 302 and
+// 303 switch to a GET without a body, except that HEAD stays HEAD; 307 and
+// 308 repeat the request as is, which is impossible for a streamed body, in
+// which case ok is false. Credentials are not sent to a different host.
+func redirectRequest(req *request.Request, base *url.URL, location string, code response.StatusCode) (*request.Request, bool, error) {
+	ref, err := url.Parse(location)
+	if err != nil {
+		return nil, false, err
+	}
+	next := base.ResolveReference(ref)
+	next.Fragment = ""
+
+	out := &request.Request{
+		RequestLine: request.RequestLine{
+			HTTPVersion: req.RequestLine.HTTPVersion,
+			Version:     req.RequestLine.Version,
+			Target:      next.String(),
+			Method:      req.RequestLine.Method,
+		},
+		Headers: headers.NewHeaders(),
+		Body:    req.Body,
+	}
+
+	keepBody := code == response.StatusTemporaryRedirect || code == response.StatusPermanentRedirect
+	if !keepBody && out.RequestLine.Method != "HEAD" {
+		out.RequestLine.Method = "GET"
+		out.Body = nil
+	}
+	if keepBody && len(req.Body) == 0 && req.BodyReader != nil {
+		return nil, false, nil
+	}
+
+	for name, value := range req.Headers.All() {
+		switch {
+		case strings.EqualFold(name, "Host"):
+			continue
+		case !keepBody && isBodyHeader(name):
+			continue
+		case next.Host != base.Host && (strings.EqualFold(name, "Authorization") || strings.EqualFold(name, "Cookie")):
+			continue
+		}
+		out.Headers.Add(name, value)
+	}
+	return out, true, nil
+}
+
+func isBodyHeader(name string) bool {
+	return strings.EqualFold(name, "Content-Length") ||
+		strings.EqualFold(name, "Content-Type") ||
+		strings.EqualFold(name, "Transfer-Encoding")
+}
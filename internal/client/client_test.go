@@ -0,0 +1,128 @@
+package client
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Dawid-Klos/httpfromtcp/internal/request"
+	"github.com/Dawid-Klos/httpfromtcp/internal/response"
+	"github.com/Dawid-Klos/httpfromtcp/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func reply(w *response.Writer, statusCode response.StatusCode, location string, body string) {
+	h := response.GetDefaultHeaders(len(body))
+	if location != "" {
+		h.Set("Location", location)
+	}
+	w.WriteStatusLine(statusCode)
+	w.WriteHeaders(h)
+	w.WriteBody([]byte(body))
+}
+
+func startServer(t *testing.T) string {
+	t.Helper()
+	s, err := server.Serve(0, func(w *response.Writer, req *request.Request) {
+		host, _ := req.Headers.Get("Host")
+		auth, _ := req.Headers.Get("Authorization")
+		switch req.RequestLine.Target {
+		case "/echo":
+			reply(w, response.StatusOK, "", fmt.Sprintf("%s %s host=%s body=%s auth=%s",
+				req.RequestLine.Method, req.RequestLine.Target, host, req.Body, auth))
+		case "/moved":
+			reply(w, response.StatusFound, "/echo", "")
+		case "/temporary":
+			reply(w, response.StatusTemporaryRedirect, "echo", "")
+		case "/loop":
+			reply(w, response.StatusFound, "/loop", "")
+		default:
+			reply(w, response.StatusNotFound, "", "not found")
+		}
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	return s.Addr().String()
+}
+
+func TestDo(t *testing.T) {
+	addr := startServer(t)
+
+	// Test: Absolute URL with the Host header defaulted
+	req, err := NewRequest("GET", "http://"+addr+"/echo", nil)
+	require.NoError(t, err)
+	resp, err := Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, response.StatusOK, resp.StatusLine.StatusCode)
+	assert.Equal(t, "GET /echo host="+addr+" body= auth=", string(resp.Body))
+	assert.Equal(t, "http://"+addr+"/echo", req.RequestLine.Target)
+
+	// Test: Origin-form target with an explicit Host header
+	req = &request.Request{RequestLine: request.RequestLine{Method: "POST", Target: "/echo"}, Body: []byte("hi")}
+	req.Headers.Set("Host", addr)
+	resp, err = Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, "POST /echo host="+addr+" body=hi auth=", string(resp.Body))
+
+	// Test: 302 turns a POST into a GET without a body
+	req, err = NewRequest("POST", "http://"+addr+"/moved", []byte("payload"))
+	require.NoError(t, err)
+	req.Headers.Set("Content-Type", "text/plain")
+	resp, err = Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, "GET /echo host="+addr+" body= auth=", string(resp.Body))
+
+	// Test: 307 repeats the method and body against a relative location
+	req, err = NewRequest("PUT", "http://"+addr+"/temporary", []byte("payload"))
+	require.NoError(t, err)
+	req.Headers.Set("Authorization", "secret")
+	resp, err = Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, "PUT /echo host="+addr+" body=payload auth=secret", string(resp.Body))
+
+	// Test: Redirect loops stop at MaxRedirects
+	req, err = NewRequest("GET", "http://"+addr+"/loop", nil)
+	require.NoError(t, err)
+	_, err = (&Client{MaxRedirects: 3}).Do(req)
+	require.ErrorIs(t, err, ErrTooManyRedirects)
+
+	// Test: Redirects are returned as is when disabled
+	resp, err = (&Client{MaxRedirects: -1}).Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, response.StatusFound, resp.StatusLine.StatusCode)
+
+	// Test: Missing host
+	req = &request.Request{RequestLine: request.RequestLine{Method: "GET", Target: "/"}}
+	_, err = Do(req)
+	require.ErrorIs(t, err, ErrMissingHost)
+
+	// Test: Unsupported scheme
+	_, err = NewRequest("GET", "https://"+addr+"/", nil)
+	require.ErrorIs(t, err, ErrUnsupportedScheme)
+}
+
+func TestTimeout(t *testing.T) {
+	// Test: A server that never answers hits the timeout
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(time.Second)
+		}
+	}()
+
+	req, err := NewRequest("GET", "http://"+listener.Addr().String()+"/", nil)
+	require.NoError(t, err)
+	start := time.Now()
+	_, err = (&Client{Timeout: 100 * time.Millisecond}).Do(req)
+	require.Error(t, err)
+	var netErr net.Error
+	require.ErrorAs(t, err, &netErr)
+	assert.True(t, netErr.Timeout())
+	assert.Less(t, time.Since(start), time.Second)
+}
@@ -0,0 +1,311 @@
+package client
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Dawid-Klos/httpfromtcp/internal/request"
+	"github.com/Dawid-Klos/httpfromtcp/internal/response"
+)
+
+var ErrPoolClosed = errors.New("connection pool closed")
+
+// testHookPutIdle runs once put has made a connection idle. Tests use it to
+// widen the window in which another request can take the connection.
+var testHookPutIdle = func() {}
+
+// Pool keeps idle keep-alive connections for reuse, keyed by host:port. While
+// a connection sits idle a goroutine watches it, so one the server closed or
+// wrote to unexpectedly is dropped instead of being handed out again.
+type Pool struct {
+	// MaxIdleConns bounds idle connections across all hosts. Zero means no
+	// limit.
+	MaxIdleConns int
+	// MaxIdleConnsPerHost bounds idle connections to a single host. Zero means
+	// no limit.
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost bounds idle and in-use connections to a single host;
+	// callers wait for a free slot once it is reached. Zero means no limit.
+	MaxConnsPerHost int
+	// IdleTimeout is how long a connection may stay idle before it is closed.
+	// Zero means no timeout.
+	IdleTimeout time.Duration
+
+	mu        sync.Mutex
+	idle      map[string][]*persistConn
+	idleCount int
+	conns     map[string]int
+	waiting   map[string]chan struct{}
+	closed    bool
+}
+
+var DefaultPool = &Pool{
+	MaxIdleConns:        100,
+	MaxIdleConnsPerHost: 4,
+	IdleTimeout:         90 * time.Second,
+}
+
+// persistConn is a connection owned by a Pool. The response reader lives as
+// long as the connection so nothing buffered is lost between responses.
+type persistConn struct {
+	pool   *Pool
+	addr   string
+	conn   net.Conn
+	reader *response.Reader
+	reused bool
+
+	// probe receives the result of the read watching the idle connection.
+	probe chan error
+}
+
+// Close closes every idle connection. Connections in use are closed when
+// they are returned.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	var idle []*persistConn
+	for _, conns := range p.idle {
+		idle = append(idle, conns...)
+	}
+	p.idle = nil
+	p.idleCount = 0
+	p.mu.Unlock()
+
+	for _, pc := range idle {
+		pc.conn.SetReadDeadline(time.Now())
+		<-pc.probe
+		p.discard(pc)
+	}
+	return nil
+}
+
+// get returns a live idle connection to addr, or dials a new one when none is
+// left and the per-host limit allows it.
+func (p *Pool) get(addr string, dial func() (net.Conn, error), deadline time.Time) (*persistConn, error) {
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	for {
+		pc, wait, err := p.take(addr)
+		if err != nil {
+			return nil, err
+		}
+		if pc != nil {
+			if pc.revive() {
+				return pc, nil
+			}
+			p.discard(pc)
+			continue
+		}
+		if wait == nil {
+			break
+		}
+
+		select {
+		case <-wait:
+		case <-timeout:
+			return nil, os.ErrDeadlineExceeded
+		}
+	}
+
+	conn, err := dial()
+	if err != nil {
+		p.release(addr)
+		return nil, err
+	}
+	return &persistConn{
+		pool:   p,
+		addr:   addr,
+		conn:   conn,
+		reader: response.NewReader(conn),
+	}, nil
+}
+
+// take pops the most recently used idle connection to addr. If there is none
+// it reserves a slot for a new connection, or returns a channel closed once a
+// connection to addr is returned or closed.
+func (p *Pool) take(addr string) (*persistConn, <-chan struct{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, nil, ErrPoolClosed
+	}
+
+	if conns := p.idle[addr]; len(conns) > 0 {
+		pc := conns[len(conns)-1]
+		p.idle[addr] = conns[:len(conns)-1]
+		p.idleCount--
+		return pc, nil, nil
+	}
+
+	if p.MaxConnsPerHost > 0 && p.conns[addr] >= p.MaxConnsPerHost {
+		if p.waiting == nil {
+			p.waiting = make(map[string]chan struct{})
+		}
+		wait, ok := p.waiting[addr]
+		if !ok {
+			wait = make(chan struct{})
+			p.waiting[addr] = wait
+		}
+		return nil, wait, nil
+	}
+
+	if p.conns == nil {
+		p.conns = make(map[string]int)
+	}
+	p.conns[addr]++
+	return nil, nil, nil
+}
+
+// put returns a connection after a complete response. It becomes idle unless
+// the pool is closed or already holds enough idle connections.
+func (p *Pool) put(pc *persistConn) {
+	p.mu.Lock()
+	full := p.closed ||
+		(p.MaxIdleConns > 0 && p.idleCount >= p.MaxIdleConns) ||
+		(p.MaxIdleConnsPerHost > 0 && len(p.idle[pc.addr]) >= p.MaxIdleConnsPerHost)
+	if full {
+		p.mu.Unlock()
+		p.discard(pc)
+		return
+	}
+
+	// The deadline is set and the watcher started while p.mu is held, before
+	// any caller can take the connection. Otherwise the deadline could replace
+	// the one revive sets to interrupt the read, leaving revive waiting on a
+	// read that never returns. watch removes the connection through p.mu, so
+	// it cannot act before the connection is on the idle list.
+	deadline := time.Time{}
+	if p.IdleTimeout > 0 {
+		deadline = time.Now().Add(p.IdleTimeout)
+	}
+	err := pc.conn.SetReadDeadline(deadline)
+	pc.reused = true
+	pc.probe = make(chan error, 1)
+	go pc.watch(err)
+
+	if p.idle == nil {
+		p.idle = make(map[string][]*persistConn)
+	}
+	p.idle[pc.addr] = append(p.idle[pc.addr], pc)
+	p.idleCount++
+	p.wake(pc.addr)
+	p.mu.Unlock()
+	testHookPutIdle()
+}
+
+// discard closes a connection and frees its per-host slot.
+func (p *Pool) discard(pc *persistConn) {
+	pc.conn.Close()
+	p.release(pc.addr)
+}
+
+func (p *Pool) release(addr string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.conns[addr]--
+	if p.conns[addr] <= 0 {
+		delete(p.conns, addr)
+	}
+	p.wake(addr)
+}
+
+// wake lets callers waiting for a connection to addr try again. The caller
+// must hold p.mu.
+func (p *Pool) wake(addr string) {
+	if wait, ok := p.waiting[addr]; ok {
+		close(wait)
+		delete(p.waiting, addr)
+	}
+}
+
+// remove takes pc off the idle list, reporting whether it was still there.
+func (p *Pool) remove(pc *persistConn) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	conns := p.idle[pc.addr]
+	for i, idle := range conns {
+		if idle == pc {
+			p.idle[pc.addr] = append(conns[:i], conns[i+1:]...)
+			p.idleCount--
+			return true
+		}
+	}
+	return false
+}
+
+// watch blocks reading the idle connection. A server has nothing to say
+// between responses, so the read only returns once the server closes the
+// connection, sends stray data, the idle timeout expires or revive interrupts
+// it to reuse the connection.
+func (pc *persistConn) watch(err error) {
+	if err == nil {
+		var buf [1]byte
+		var n int
+		n, err = pc.conn.Read(buf[:])
+		if n > 0 {
+			err = errUnexpectedData
+		}
+	}
+
+	if pc.pool.remove(pc) {
+		// Still idle, so the connection died or timed out on its own.
+		pc.pool.discard(pc)
+		return
+	}
+	pc.probe <- err
+}
+
+var errUnexpectedData = errors.New("unexpected data on idle connection")
+
+// revive stops the watching read on a connection taken off the idle list and
+// reports whether the connection is still usable.
+func (pc *persistConn) revive() bool {
+	pc.conn.SetReadDeadline(time.Now())
+	err := <-pc.probe
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		return false
+	}
+	return pc.conn.SetReadDeadline(time.Time{}) == nil
+}
+
+// roundTrip writes req and reads its final response. retryable reports that
+// the connection failed before the server sent any part of a response, which
+// on a reused connection usually means the server closed it while idle.
+func (pc *persistConn) roundTrip(req *request.Request, method string, deadline time.Time) (resp *response.Response, retryable bool, err error) {
+	err = pc.conn.SetDeadline(deadline)
+	if err != nil {
+		return nil, false, err
+	}
+
+	_, err = req.WriteTo(pc.conn)
+	if err != nil {
+		return nil, !isTimeout(err), err
+	}
+
+	resp, err = readFinalResponse(pc.reader, method)
+	if err != nil {
+		return nil, errors.Is(err, io.EOF) || isReset(err), err
+	}
+	return resp, false, nil
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// isReset reports whether err is a connection reset rather than a timeout or
+// a malformed response.
+func isReset(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && !opErr.Timeout()
+}
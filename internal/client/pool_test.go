@@ -0,0 +1,250 @@
+package client
+
+import (
+	"io"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Dawid-Klos/httpfromtcp/internal/request"
+	"github.com/Dawid-Klos/httpfromtcp/internal/response"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testServer answers "ok" on keep-alive connections. With hangUpAfter set,
+// it closes a connection without replying to the request that follows that
+// many answered ones; with closeAfterReply set, it closes every connection
+// right after the first response.
+type testServer struct {
+	listener        net.Listener
+	hangUpAfter     int
+	closeAfterReply bool
+	accepted        atomic.Int32
+}
+
+func startTestServer(t *testing.T, ts *testServer) *testServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ts.listener = listener
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			ts.accepted.Add(1)
+			go ts.serve(conn)
+		}
+	}()
+	return ts
+}
+
+func (ts *testServer) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := request.NewReader(conn)
+	for answered := 0; ; answered++ {
+		_, err := reader.ReadRequest()
+		if err != nil {
+			return
+		}
+		if ts.hangUpAfter > 0 && answered == ts.hangUpAfter {
+			return
+		}
+		w := response.NewWriter(conn)
+		w.WriteStatusLine(response.StatusOK)
+		w.WriteHeaders(response.GetDefaultHeaders(2))
+		w.WriteBody([]byte("ok"))
+		if ts.closeAfterReply {
+			return
+		}
+	}
+}
+
+func (ts *testServer) url() string {
+	return "http://" + ts.listener.Addr().String() + "/"
+}
+
+func get(t *testing.T, c *Client, method string, url string) (*response.Response, error) {
+	t.Helper()
+	req, err := NewRequest(method, url, nil)
+	require.NoError(t, err)
+	return c.Do(req)
+}
+
+func idleConns(p *Pool) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.idleCount
+}
+
+func TestPoolReuse(t *testing.T) {
+	// Test: Sequential requests share one connection
+	ts := startTestServer(t, &testServer{})
+	pool := &Pool{}
+	defer pool.Close()
+	c := &Client{Pool: pool}
+	for range 3 {
+		resp, err := get(t, c, "GET", ts.url())
+		require.NoError(t, err)
+		assert.Equal(t, "ok", string(resp.Body))
+	}
+	assert.Equal(t, int32(1), ts.accepted.Load())
+
+	// Test: Connection: close is honoured
+	req, err := NewRequest("GET", ts.url(), nil)
+	require.NoError(t, err)
+	req.Headers.Set("Connection", "close")
+	_, err = c.Do(req)
+	require.NoError(t, err)
+	_, err = get(t, c, "GET", ts.url())
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), ts.accepted.Load())
+
+	// Test: Idle connections expire
+	ts = startTestServer(t, &testServer{})
+	pool = &Pool{IdleTimeout: 20 * time.Millisecond}
+	defer pool.Close()
+	c = &Client{Pool: pool}
+	_, err = get(t, c, "GET", ts.url())
+	require.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 0, idleConns(pool))
+	_, err = get(t, c, "GET", ts.url())
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), ts.accepted.Load())
+
+	// Test: Idle limit per host
+	ts = startTestServer(t, &testServer{})
+	pool = &Pool{MaxIdleConnsPerHost: 1}
+	defer pool.Close()
+	c = &Client{Pool: pool}
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := get(t, c, "GET", ts.url())
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.LessOrEqual(t, idleConns(pool), 1)
+}
+
+func TestPoolConcurrentReuse(t *testing.T) {
+	// Test: A connection taken right after it became idle is revived
+	for _, idleTimeout := range []time.Duration{0, time.Minute} {
+		ts := startTestServer(t, &testServer{})
+		pool := &Pool{IdleTimeout: idleTimeout}
+		c := &Client{Pool: pool}
+		testHookPutIdle = func() { time.Sleep(time.Millisecond) }
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			var wg sync.WaitGroup
+			for range 8 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for range 20 {
+						resp, err := get(t, c, "GET", ts.url())
+						if assert.NoError(t, err) {
+							assert.Equal(t, "ok", string(resp.Body))
+						}
+					}
+				}()
+			}
+			wg.Wait()
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			t.Fatalf("requests hung reusing idle connections (idle timeout %v)", idleTimeout)
+		}
+		testHookPutIdle = func() {}
+		pool.Close()
+	}
+}
+
+func TestPoolDeadConnections(t *testing.T) {
+	// Test: Connection closed by the server while idle is not reused
+	ts := startTestServer(t, &testServer{closeAfterReply: true})
+	pool := &Pool{}
+	defer pool.Close()
+	c := &Client{Pool: pool}
+	for range 3 {
+		resp, err := get(t, c, "GET", ts.url())
+		require.NoError(t, err)
+		assert.Equal(t, "ok", string(resp.Body))
+		// Give the watcher a moment to see the connection close.
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, int32(3), ts.accepted.Load())
+
+	// Test: Idempotent request retried when a reused connection is dead
+	ts = startTestServer(t, &testServer{hangUpAfter: 1})
+	pool = &Pool{}
+	defer pool.Close()
+	c = &Client{Pool: pool}
+	_, err := get(t, c, "GET", ts.url())
+	require.NoError(t, err)
+	resp, err := get(t, c, "GET", ts.url())
+	require.NoError(t, err)
+	assert.Equal(t, "ok", string(resp.Body))
+	assert.Equal(t, int32(2), ts.accepted.Load())
+
+	// Test: Non-idempotent request is not retried
+	ts = startTestServer(t, &testServer{hangUpAfter: 1})
+	pool = &Pool{}
+	defer pool.Close()
+	c = &Client{Pool: pool}
+	_, err = get(t, c, "POST", ts.url())
+	require.NoError(t, err)
+	_, err = get(t, c, "POST", ts.url())
+	require.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, int32(1), ts.accepted.Load())
+}
+
+func TestPoolLimits(t *testing.T) {
+	// Test: Requests wait for a slot when the host limit is reached
+	ts := startTestServer(t, &testServer{})
+	pool := &Pool{MaxConnsPerHost: 1}
+	defer pool.Close()
+	c := &Client{Pool: pool}
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := get(t, c, "GET", ts.url())
+			if assert.NoError(t, err) {
+				assert.Equal(t, "ok", string(resp.Body))
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), ts.accepted.Load())
+
+	// Test: Waiting for a slot respects the deadline
+	addr := ts.listener.Addr().String()
+	pc, err := pool.get(addr, func() (net.Conn, error) { return net.Dial("tcp", addr) }, time.Time{})
+	require.NoError(t, err)
+	_, err = pool.get(addr, nil, time.Now().Add(20*time.Millisecond))
+	require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	pool.discard(pc)
+
+	// Test: Closed pool
+	require.NoError(t, pool.Close())
+	_, err = get(t, c, "GET", ts.url())
+	require.ErrorIs(t, err, ErrPoolClosed)
+}
@@ -0,0 +1,205 @@
+// Package cookie parses the Cookie request header and serializes Set-Cookie
+// response headers as defined in RFC 6265.
+//
+// Parse splits a Cookie field value into name/value pairs, keeping the pairs
+// that are valid when others are not. A Cookie is written as the value of a
+// single Set-Cookie field; SetCookie adds it to a headers.Headers value as a
+// field of its own, since Set-Cookie values cannot be combined into one line.
+package cookie
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Dawid-Klos/httpfromtcp/internal/headers"
+)
+
+// SameSite restricts when a browser sends a cookie along with cross-site
+// requests.
+type SameSite string
+
+const (
+	SameSiteDefault SameSite = ""
+	SameSiteLax     SameSite = "Lax"
+	SameSiteStrict  SameSite = "Strict"
+	SameSiteNone    SameSite = "None"
+)
+
+// expiresFormat is the IMF-fixdate format RFC 6265 expects in Expires.
+const expiresFormat = "Mon, 02 Jan 2006 15:04:05 GMT"
+
+var ErrMalformedCookie = errors.New("malformed cookie")
+var ErrInvalidCookieName = errors.New("invalid cookie name")
+var ErrInvalidCookieValue = errors.New("invalid cookie value")
+var ErrInvalidCookieAttribute = errors.New("invalid cookie attribute value")
+var ErrInvalidSameSite = errors.New("invalid SameSite value")
+var ErrPartitionedNotSecure = errors.New("partitioned cookie must be secure")
+
+// Cookie is a cookie sent by a client, or set by a server with Set-Cookie.
+// A client only sends Name and Value; the other fields are attributes of a
+// Set-Cookie field.
+type Cookie struct {
+	Name  string
+	Value string
+
+	Path    string
+	Domain  string
+	Expires time.Time
+	// MaxAge is the lifetime of the cookie in seconds. Zero leaves it unset,
+	// and a negative value deletes the cookie at once.
+	MaxAge   int
+	Secure   bool
+	HttpOnly bool
+	SameSite SameSite
+	// Partitioned keeps the cookie in storage partitioned by the top-level
+	// site (CHIPS). It requires Secure.
+	Partitioned bool
+}
+
+// Parse parses the value of a Cookie field, a list of name=value pairs
+// separated by semicolons. Pairs that are malformed are skipped and reported
+// with ErrMalformedCookie, while the valid ones are still returned.
+func Parse(line string) ([]Cookie, error) {
+	var cookies []Cookie
+	var err error
+	for _, pair := range strings.Split(line, ";") {
+		pair = strings.Trim(pair, headers.OWS)
+		if pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok || !validName(name) {
+			err = ErrMalformedCookie
+			continue
+		}
+		value, ok = parseValue(value)
+		if !ok {
+			err = ErrMalformedCookie
+			continue
+		}
+		cookies = append(cookies, Cookie{Name: name, Value: value})
+	}
+	return cookies, err
+}
+
+// Valid reports whether the cookie can be serialized into a Set-Cookie
+// field.
+func (c Cookie) Valid() error {
+	if !validName(c.Name) {
+		return ErrInvalidCookieName
+	}
+	if !validValue(c.Value) {
+		return ErrInvalidCookieValue
+	}
+	if !validAttribute(c.Path) || !validAttribute(c.Domain) {
+		return ErrInvalidCookieAttribute
+	}
+	if !c.Expires.IsZero() && c.Expires.UTC().Year() < 1601 {
+		return ErrInvalidCookieAttribute
+	}
+	switch c.SameSite {
+	case SameSiteDefault, SameSiteLax, SameSiteStrict, SameSiteNone:
+	default:
+		return ErrInvalidSameSite
+	}
+	if c.Partitioned && !c.Secure {
+		return ErrPartitionedNotSecure
+	}
+	return nil
+}
+
+// String returns the cookie serialized as a Set-Cookie field value. The
+// result is only meaningful for a cookie that passes Valid.
+func (c Cookie) String() string {
+	var b strings.Builder
+	b.WriteString(c.Name)
+	b.WriteByte('=')
+	b.WriteString(c.Value)
+
+	if c.Path != "" {
+		b.WriteString("; Path=" + c.Path)
+	}
+	if c.Domain != "" {
+		// A leading dot is ignored by user agents, RFC 6265 section 5.2.3.
+		b.WriteString("; Domain=" + strings.TrimPrefix(c.Domain, "."))
+	}
+	if !c.Expires.IsZero() {
+		b.WriteString("; Expires=" + c.Expires.UTC().Format(expiresFormat))
+	}
+	if c.MaxAge > 0 {
+		b.WriteString("; Max-Age=" + strconv.Itoa(c.MaxAge))
+	} else if c.MaxAge < 0 {
+		b.WriteString("; Max-Age=0")
+	}
+	if c.Secure {
+		b.WriteString("; Secure")
+	}
+	if c.HttpOnly {
+		b.WriteString("; HttpOnly")
+	}
+	if c.SameSite != SameSiteDefault {
+		b.WriteString("; SameSite=" + string(c.SameSite))
+	}
+	if c.Partitioned {
+		b.WriteString("; Partitioned")
+	}
+	return b.String()
+}
+
+// SetCookie adds a Set-Cookie field for the cookie to h. Each cookie gets a
+// field of its own, which WriteHeaders sends as a separate line.
+func SetCookie(h *headers.Headers, c Cookie) error {
+	err := c.Valid()
+	if err != nil {
+		return err
+	}
+	h.Add("Set-Cookie", c.String())
+	return nil
+}
+
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !headers.IsTokenChar(name[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseValue strips the optional double quotes around a cookie-value and
+// validates what is left.
+func parseValue(value string) (string, bool) {
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		value = value[1 : len(value)-1]
+	}
+	return value, validValue(value)
+}
+
+// validValue reports whether every byte of value is a cookie-octet: visible
+// ASCII except the double quote, comma, semicolon and backslash.
+func validValue(value string) bool {
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c <= ' ' || c >= 0x7f || c == '"' || c == ',' || c == ';' || c == '\\' {
+			return false
+		}
+	}
+	return true
+}
+
+// validAttribute reports whether value can be written as an attribute value
+// without ending the attribute early.
+func validAttribute(value string) bool {
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c < ' ' || c >= 0x7f || c == ';' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,116 @@
+package cookie
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Dawid-Klos/httpfromtcp/internal/headers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	// Test: Name/value pairs
+	cookies, err := Parse("session=abc123; theme=dark;lang=en")
+	require.NoError(t, err)
+	assert.Equal(t, []Cookie{
+		{Name: "session", Value: "abc123"},
+		{Name: "theme", Value: "dark"},
+		{Name: "lang", Value: "en"},
+	}, cookies)
+
+	// Test: Quoted and empty values
+	cookies, err = Parse(`id="42"; empty=; flag=""`)
+	require.NoError(t, err)
+	assert.Equal(t, []Cookie{
+		{Name: "id", Value: "42"},
+		{Name: "empty", Value: ""},
+		{Name: "flag", Value: ""},
+	}, cookies)
+
+	// Test: Values may contain '='
+	cookies, err = Parse("token=a=b==")
+	require.NoError(t, err)
+	assert.Equal(t, []Cookie{{Name: "token", Value: "a=b=="}}, cookies)
+
+	// Test: Empty field value
+	cookies, err = Parse("  ")
+	require.NoError(t, err)
+	assert.Empty(t, cookies)
+
+	// Test: Malformed pairs are skipped but reported
+	cookies, err = Parse("a=1; novalue; b=2; c d=3; e=x y; f=\"4")
+	assert.ErrorIs(t, err, ErrMalformedCookie)
+	assert.Equal(t, []Cookie{
+		{Name: "a", Value: "1"},
+		{Name: "b", Value: "2"},
+	}, cookies)
+}
+
+func TestString(t *testing.T) {
+	// Test: Name and value only
+	assert.Equal(t, "a=1", Cookie{Name: "a", Value: "1"}.String())
+
+	// Test: Every attribute
+	c := Cookie{
+		Name:        "session",
+		Value:       "abc123",
+		Path:        "/app",
+		Domain:      ".example.com",
+		Expires:     time.Date(2030, time.March, 4, 5, 6, 7, 0, time.FixedZone("CET", 3600)),
+		MaxAge:      3600,
+		Secure:      true,
+		HttpOnly:    true,
+		SameSite:    SameSiteStrict,
+		Partitioned: true,
+	}
+	require.NoError(t, c.Valid())
+	assert.Equal(t, "session=abc123; Path=/app; Domain=example.com; "+
+		"Expires=Mon, 04 Mar 2030 04:06:07 GMT; Max-Age=3600; Secure; HttpOnly; "+
+		"SameSite=Strict; Partitioned", c.String())
+
+	// Test: Negative MaxAge deletes the cookie
+	assert.Equal(t, "a=; Max-Age=0", Cookie{Name: "a", MaxAge: -1}.String())
+}
+
+func TestValid(t *testing.T) {
+	// Test: Invalid cookies
+	testCases := []struct {
+		cookie Cookie
+		err    error
+	}{
+		{Cookie{Name: ""}, ErrInvalidCookieName},
+		{Cookie{Name: "a b"}, ErrInvalidCookieName},
+		{Cookie{Name: "a", Value: "x;y"}, ErrInvalidCookieValue},
+		{Cookie{Name: "a", Value: "x y"}, ErrInvalidCookieValue},
+		{Cookie{Name: "a", Path: "/;Domain=evil.com"}, ErrInvalidCookieAttribute},
+		{Cookie{Name: "a", Domain: "example.com\r\n"}, ErrInvalidCookieAttribute},
+		{Cookie{Name: "a", Expires: time.Date(1500, time.January, 1, 0, 0, 0, 0, time.UTC)}, ErrInvalidCookieAttribute},
+		{Cookie{Name: "a", SameSite: "lax"}, ErrInvalidSameSite},
+		{Cookie{Name: "a", Partitioned: true}, ErrPartitionedNotSecure},
+	}
+	for _, tc := range testCases {
+		assert.ErrorIs(t, tc.cookie.Valid(), tc.err, tc.cookie)
+	}
+}
+
+func TestSetCookie(t *testing.T) {
+	// Test: Each cookie gets its own Set-Cookie field
+	h := headers.NewHeaders()
+	h.Set("Content-Length", "0")
+	require.NoError(t, SetCookie(&h, Cookie{Name: "a", Value: "1", Expires: time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)}))
+	require.NoError(t, SetCookie(&h, Cookie{Name: "b", Value: "2", HttpOnly: true}))
+	assert.Equal(t, []string{"a=1; Expires=Tue, 01 Jan 2030 00:00:00 GMT", "b=2; HttpOnly"}, h.Values("Set-Cookie"))
+	lines := 0
+	for name := range h.All() {
+		if name == "Set-Cookie" {
+			lines++
+		}
+	}
+	assert.Equal(t, 2, lines)
+
+	// Test: Invalid cookies are not added
+	err := SetCookie(&h, Cookie{Name: "c", Value: "x;y"})
+	assert.ErrorIs(t, err, ErrInvalidCookieValue)
+	assert.Len(t, h.Values("Set-Cookie"), 2)
+}
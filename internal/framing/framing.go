@@ -0,0 +1,93 @@
+// Package framing holds the parts of HTTP/1.1 message framing that the
+// request and response parsers share: Content-Length parsing, size limits
+// and the connection options listed in field values.
+package framing
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/Dawid-Klos/httpfromtcp/internal/headers"
+)
+
+var ErrMalformedContentLength = errors.New("malformed Content-Length")
+var ErrSignedContentLength = errors.New("signed Content-Length")
+var ErrConflictingContentLength = errors.New("conflicting Content-Length values")
+
+// ParseContentLength parses every Content-Length value, each of which may be
+// a list. Repeats of the same length are accepted as one, as RFC 9110 section
+// 8.6 allows.
+func ParseContentLength(values []string) (int64, error) {
+	contentLen := int64(-1)
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			part = strings.Trim(part, headers.OWS)
+			if part == "" {
+				return 0, ErrMalformedContentLength
+			}
+			if part[0] == '+' || part[0] == '-' {
+				return 0, ErrSignedContentLength
+			}
+			for i := 0; i < len(part); i++ {
+				if part[i] < '0' || part[i] > '9' {
+					return 0, ErrMalformedContentLength
+				}
+			}
+			n, err := strconv.ParseInt(part, 10, 64)
+			if err != nil {
+				return 0, ErrMalformedContentLength
+			}
+			if contentLen != -1 && n != contentLen {
+				return 0, ErrConflictingContentLength
+			}
+			contentLen = n
+		}
+	}
+	if contentLen == -1 {
+		return 0, ErrMalformedContentLength
+	}
+	return contentLen, nil
+}
+
+// Exceeds reports whether n is over limit, where a zero limit means none.
+func Exceeds[T ~int | ~int64](n T, limit T) bool {
+	return limit > 0 && n > limit
+}
+
+// SectionSize tracks the size of a header or trailer section as its field
+// lines are parsed. The zero value is an empty section.
+type SectionSize struct {
+	n int
+}
+
+// Add counts a parsed field line of n bytes and reports whether the section
+// is still within limit. When no complete line is available yet, the buffered
+// data counts instead so an endless line is rejected early.
+func (s *SectionSize) Add(n int, data []byte, limit int) bool {
+	pending := n
+	if n == 0 {
+		pending = len(data)
+	}
+	if Exceeds(s.n+pending, limit) {
+		return false
+	}
+	s.n += n
+	return true
+}
+
+// Reset starts counting a new section.
+func (s *SectionSize) Reset() {
+	s.n = 0
+}
+
+// HasToken reports whether the comma-separated list contains token, compared
+// case-insensitively.
+func HasToken(list string, token string) bool {
+	for _, part := range strings.Split(list, ",") {
+		if strings.EqualFold(strings.TrimSpace(part), token) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package framing
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseContentLength(t *testing.T) {
+	// Test: Single value
+	n, err := ParseContentLength([]string{"42"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), n)
+
+	// Test: Repeated identical values, in lists and separate fields
+	n, err = ParseContentLength([]string{"5, 5", " 5 "})
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), n)
+
+	// Test: Invalid values
+	testCases := []struct {
+		values []string
+		err    error
+	}{
+		{[]string{""}, ErrMalformedContentLength},
+		{[]string{"5,"}, ErrMalformedContentLength},
+		{[]string{"0x10"}, ErrMalformedContentLength},
+		{[]string{"99999999999999999999"}, ErrMalformedContentLength},
+		{[]string{"+5"}, ErrSignedContentLength},
+		{[]string{"-1"}, ErrSignedContentLength},
+		{[]string{"5", "6"}, ErrConflictingContentLength},
+		{nil, ErrMalformedContentLength},
+	}
+	for _, tc := range testCases {
+		_, err := ParseContentLength(tc.values)
+		assert.ErrorIs(t, err, tc.err, tc.values)
+	}
+}
+
+func TestSectionSize(t *testing.T) {
+	// Test: Lines are counted up to the limit
+	var s SectionSize
+	assert.True(t, s.Add(6, []byte("a: b\r\n"), 10))
+	assert.False(t, s.Add(6, []byte("c: d\r\n"), 10))
+
+	// Test: Incomplete lines count the buffered data
+	s.Reset()
+	assert.True(t, s.Add(0, []byte("short"), 10))
+	assert.False(t, s.Add(0, []byte(strings.Repeat("x", 11)), 10))
+
+	// Test: Zero limit means no limit
+	assert.True(t, s.Add(1<<20, nil, 0))
+}
+
+func TestHasToken(t *testing.T) {
+	assert.True(t, HasToken("keep-alive, Close", "close"))
+	assert.True(t, HasToken(" close ", "close"))
+	assert.False(t, HasToken("closed", "close"))
+	assert.False(t, HasToken("", "close"))
+}
@@ -2,21 +2,86 @@
 //
 // It incrementally reads field-name and field-value pairs until the blank
 // line is found that terminates the headers section. Field names are validated
-// according to HTTP token rules and normalized to lowercase. Duplicate headers
-// are combined into a single, comma-separated value.
+// according to HTTP token rules, field values have their surrounding
+// whitespace trimmed and must not contain control characters. Fields keep the
+// order and the field-name casing they were received with, while lookups are
+// case-insensitive. Duplicate fields are kept as separate values.
 package headers
 
 import (
 	"bytes"
 	"errors"
-	"fmt"
+	"iter"
+	"slices"
+	"strings"
+	"unsafe"
 )
 
-type Headers map[string]string
+type field struct {
+	name  string
+	value string
+}
+
+// Headers is an ordered list of header fields. The zero value is an empty
+// set of headers ready to use.
+//
+// Like a map, a Headers value refers to its fields rather than holding them,
+// so copies of a value returned by NewHeaders, or of one already holding
+// fields, see each other's changes. Use Clone for a copy that can be changed
+// independently.
+type Headers struct {
+	list *fieldList
+}
+
+type fieldList struct {
+	fields []field
+	// arena holds the parsed field names and values, which are string views
+	// into it. Bytes are only ever appended to it, so the strings stay intact
+	// for as long as they are in use.
+	arena *arena
+}
+
+// arenaSize is enough for the header section of a typical request. Fields
+// that do not fit are allocated one by one.
+const arenaSize = 1024
+
+type arena struct {
+	n   int
+	buf [arenaSize]byte
+}
+
+// fieldsSize is how many fields a Headers value has room for before its list
+// of fields grows.
+const fieldsSize = 16
 
 func NewHeaders() Headers {
-	headers := make(map[string]string, 16)
-	return headers
+	return Headers{
+		list: &fieldList{fields: make([]field, 0, fieldsSize)},
+	}
+}
+
+// Storage is the memory behind a Headers value: room for the fields of a
+// typical header section and an arena for their names and values. Embedding
+// it in a struct that lives as long as the headers, as a parsed request does,
+// saves allocating either on its own. A Storage backs a single Headers value
+// and must not be copied once in use.
+type Storage struct {
+	list   fieldList
+	arena  arena
+	fields [fieldsSize]field
+}
+
+// Headers returns an empty Headers value backed by s.
+func (s *Storage) Headers() Headers {
+	s.list = fieldList{fields: s.fields[:0], arena: &s.arena}
+	return Headers{list: &s.list}
+}
+
+// Intern copies b into the arena of s and returns the copy as a string, so
+// that text parsed along with the header section, such as the request line,
+// needs no allocation of its own either.
+func (s *Storage) Intern(b []byte) string {
+	return s.arena.intern(b)
 }
 
 var validHeaderChar [256]bool
@@ -25,9 +90,13 @@ var CRLF = []byte("\r\n")
 var COLON = []byte(":")
 var WHITESPACE = []byte(" ")
 
+// OWS is the optional whitespace allowed around a field-value.
+const OWS = " \t"
+
 var ErrMalformedFieldName = errors.New("malformed field-name")
 var ErrMalformedFieldValue = errors.New("malformed field-value")
 var ErrFieldNameNotFound = errors.New("field-name not found")
+var ErrWhitespaceBeforeColon = errors.New("whitespace between field-name and colon")
 
 func init() {
 	for c := 'a'; c <= 'z'; c++ {
@@ -44,16 +113,115 @@ func init() {
 	}
 }
 
-func (h Headers) Get(str string) (string, error) {
-	key := bytes.ToLower([]byte(str))
-	value, ok := h[string(key)]
-	if !ok {
-		return "", ErrFieldNameNotFound
+// Get returns the value of the named field. Multiple values are combined into
+// a single, comma-separated value; use Values for fields such as Set-Cookie
+// that cannot be combined.
+func (h *Headers) Get(name string) (string, error) {
+	fields := h.fields()
+	for i, f := range fields {
+		if !strings.EqualFold(f.name, name) {
+			continue
+		}
+		for _, rest := range fields[i+1:] {
+			if strings.EqualFold(rest.name, name) {
+				return strings.Join(h.Values(name), ","), nil
+			}
+		}
+		return f.value, nil
+	}
+	return "", ErrFieldNameNotFound
+}
+
+// Values returns every value of the named field in the order received.
+func (h *Headers) Values(name string) []string {
+	var values []string
+	for _, f := range h.fields() {
+		if strings.EqualFold(f.name, name) {
+			values = append(values, f.value)
+		}
+	}
+	return values
+}
+
+// Add appends a value to the named field, keeping any existing values.
+func (h *Headers) Add(name string, value string) {
+	l := h.init()
+	l.fields = append(l.fields, field{name: name, value: value})
+}
+
+// Set replaces all values of the named field with value. The field keeps the
+// position of its first occurrence, or is appended if it was not present.
+func (h *Headers) Set(name string, value string) {
+	fields := h.fields()
+	for i, f := range fields {
+		if strings.EqualFold(f.name, name) {
+			fields[i] = field{name: name, value: value}
+			h.del(name, i+1)
+			return
+		}
+	}
+	h.Add(name, value)
+}
+
+// Del removes every value of the named field.
+func (h *Headers) Del(name string) {
+	h.del(name, 0)
+}
+
+func (h *Headers) del(name string, from int) {
+	if h.list == nil {
+		return
+	}
+	l := h.list
+	fields := l.fields[:from]
+	for _, f := range l.fields[from:] {
+		if !strings.EqualFold(f.name, name) {
+			fields = append(fields, f)
+		}
+	}
+	clear(l.fields[len(fields):])
+	l.fields = fields
+}
+
+// Clone returns a copy of h that can be changed independently of h.
+func (h *Headers) Clone() Headers {
+	return Headers{list: &fieldList{fields: slices.Clone(h.fields())}}
+}
+
+// Len returns the number of fields, counting each value of a repeated field.
+func (h *Headers) Len() int {
+	return len(h.fields())
+}
+
+// fields returns the fields of h, which are nil until the first one is added.
+func (h *Headers) fields() []field {
+	if h.list == nil {
+		return nil
+	}
+	return h.list.fields
+}
+
+// init returns the field list of h, creating it on the first change.
+func (h *Headers) init() *fieldList {
+	if h.list == nil {
+		h.list = &fieldList{}
+	}
+	return h.list
+}
+
+// All iterates over the fields in order, yielding each field-name with its
+// original casing alongside its value.
+func (h *Headers) All() iter.Seq2[string, string] {
+	return func(yield func(string, string) bool) {
+		for _, f := range h.fields() {
+			if !yield(f.name, f.value) {
+				return
+			}
+		}
 	}
-	return value, nil
 }
 
-func (h Headers) Parse(data []byte) (n int, done bool, err error) {
+func (h *Headers) Parse(data []byte) (n int, done bool, err error) {
 	crlfIdx := bytes.Index(data, CRLF)
 	if crlfIdx == -1 {
 		return 0, false, nil
@@ -63,41 +231,101 @@ func (h Headers) Parse(data []byte) (n int, done bool, err error) {
 	}
 
 	header := data[:crlfIdx]
-	colonIdx := bytes.Index(header, COLON)
+	colonIdx := bytes.IndexByte(header, ':')
+	if colonIdx == -1 {
+		return 0, false, ErrMalformedFieldName
+	}
 
 	fieldName, err := h.validateFieldName(header[:colonIdx])
 	if err != nil {
 		return 0, false, err
 	}
 
-	fieldValue, err := h.validateFieldValue(header[colonIdx+2:])
+	fieldValue, err := h.validateFieldValue(header[colonIdx+1:])
 	if err != nil {
 		return 0, false, err
 	}
 
-	field := string(bytes.ToLower(fieldName))
-	value, ok := h[field]
-	if !ok {
-		h[field] = string(fieldValue)
-	} else {
-		h[field] = fmt.Sprintf("%s,%s", value, string(fieldValue))
-	}
+	h.Add(h.intern(fieldName), h.intern(fieldValue))
 
 	return crlfIdx + 2, false, nil
 }
 
-func (h Headers) validateFieldValue(b []byte) ([]byte, error) {
-	fieldValue := bytes.TrimRight(b, string(WHITESPACE))
-	if bytes.Contains(fieldValue, WHITESPACE) {
+// intern copies b into the arena and returns a string view of the copy.
+func (h *Headers) intern(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	l := h.init()
+	if l.arena == nil {
+		l.arena = &arena{}
+	}
+	return l.arena.intern(b)
+}
+
+// intern copies b into the arena and returns a string view of the copy. The
+// bytes are never written again, which keeps the string immutable. When the
+// arena is full the string is allocated on its own.
+func (a *arena) intern(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	if arenaSize-a.n < len(b) {
+		return string(b)
+	}
+	start := a.n
+	a.n += copy(a.buf[start:], b)
+	return unsafe.String(&a.buf[start], len(b))
+}
+
+// validateFieldValue trims the optional whitespace around a field-value and
+// checks what remains against RFC 9110 section 5.5: visible characters,
+// SP and HTAB between them, and obs-text. Any other control character,
+// including a bare CR or LF, is rejected.
+func (h *Headers) validateFieldValue(b []byte) ([]byte, error) {
+	fieldValue := bytes.Trim(b, OWS)
+	if !validFieldValue(fieldValue) {
 		return nil, ErrMalformedFieldValue
 	}
 
 	return fieldValue, nil
 }
-func (h Headers) validateFieldName(b []byte) ([]byte, error) {
+
+func validFieldValue[T string | []byte](value T) bool {
+	for i := 0; i < len(value); i++ {
+		if isCTL(value[i]) && value[i] != '\t' {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate checks every field against the rules Parse applies: names must be
+// tokens, and values must not contain control characters other than HTAB.
+// Fields are checked before they are written to the wire, where a CR or LF
+// in a name or value would end the line and inject fields of its own.
+func (h *Headers) Validate() error {
+	for name, value := range h.All() {
+		if name == "" || !isToken(name) {
+			return ErrMalformedFieldName
+		}
+		if !validFieldValue(value) {
+			return ErrMalformedFieldValue
+		}
+	}
+	return nil
+}
+
+func (h *Headers) validateFieldName(b []byte) ([]byte, error) {
+	// RFC 9112 section 5.1 forbids whitespace before the colon, as
+	// intermediaries disagree on which field such a line names.
+	if len(b) > 0 && strings.IndexByte(OWS, b[len(b)-1]) != -1 {
+		return nil, ErrWhitespaceBeforeColon
+	}
+
 	fieldName := bytes.TrimLeft(b, string(WHITESPACE))
 
-	if bytes.Contains(fieldName, WHITESPACE) {
+	if len(fieldName) == 0 || bytes.Contains(fieldName, WHITESPACE) {
 		return nil, ErrMalformedFieldName
 	}
 
@@ -109,14 +337,20 @@ func (h Headers) validateFieldName(b []byte) ([]byte, error) {
 	return fieldName, nil
 }
 
-func isToken(b []byte) bool {
-	isValid := true
-	for _, char := range b {
-		if !validHeaderChar[char] {
-			isValid = false
-			break
+// IsTokenChar reports whether c is a valid tchar as defined in RFC 9110.
+func IsTokenChar(c byte) bool {
+	return validHeaderChar[c]
+}
+
+func isCTL(c byte) bool {
+	return c < 0x20 || c == 0x7f
+}
+
+func isToken[T string | []byte](b T) bool {
+	for i := 0; i < len(b); i++ {
+		if !validHeaderChar[b[i]] {
+			return false
 		}
 	}
-
-	return isValid
+	return true
 }
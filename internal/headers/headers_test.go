@@ -1,12 +1,19 @@
 package headers
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+// get returns the named header value, or an empty string if it is missing.
+func get(h Headers, name string) string {
+	value, _ := h.Get(name)
+	return value
+}
+
 func TestHeaders(t *testing.T) {
 	// Test: Valid single header
 	headers := NewHeaders()
@@ -14,7 +21,7 @@ func TestHeaders(t *testing.T) {
 	n, done, err := headers.Parse(data)
 	require.NoError(t, err)
 	require.NotNil(t, headers)
-	assert.Equal(t, "localhost:42069", headers["host"])
+	assert.Equal(t, "localhost:42069", get(headers, "host"))
 	assert.Equal(t, 23, n)
 	assert.False(t, done)
 
@@ -24,33 +31,31 @@ func TestHeaders(t *testing.T) {
 	n, done, err = headers.Parse(data)
 	require.NoError(t, err)
 	require.NotNil(t, headers)
-	assert.Equal(t, "localhost:42069", headers["host"])
+	assert.Equal(t, "localhost:42069", get(headers, "host"))
 	assert.Equal(t, 38, n)
 	assert.False(t, done)
 
 	// Test: Valid 2 headers with existing headers
-	headers = Headers{
-		"content-type":   "application/json",
-		"accept-charset": "utf-8",
-	}
+	headers = NewHeaders()
+	headers.Add("Content-Type", "application/json")
+	headers.Add("Accept-Charset", "utf-8")
 	data = []byte("Host: localhost:42069\r\n\r\n")
 	n, done, err = headers.Parse(data)
 	require.NoError(t, err)
 	require.NotNil(t, headers)
-	assert.Equal(t, "localhost:42069", headers["host"])
-	assert.Equal(t, "utf-8", headers["accept-charset"])
+	assert.Equal(t, "localhost:42069", get(headers, "host"))
+	assert.Equal(t, "utf-8", get(headers, "accept-charset"))
 	assert.Equal(t, 23, n)
 	assert.False(t, done)
 
 	// Test: Valid 2 headers with existing headers
-	headers = Headers{
-		"content-type": "application/json",
-	}
+	headers = NewHeaders()
+	headers.Add("Content-Type", "application/json")
 	data = []byte("Content-Type: application/x-www-form-urlencoded\r\n\r\n")
 	n, done, err = headers.Parse(data)
 	require.NoError(t, err)
 	require.NotNil(t, headers)
-	assert.Equal(t, "application/json,application/x-www-form-urlencoded", headers["content-type"])
+	assert.Equal(t, "application/json,application/x-www-form-urlencoded", get(headers, "content-type"))
 	assert.Equal(t, 49, n)
 	assert.False(t, done)
 
@@ -62,6 +67,12 @@ func TestHeaders(t *testing.T) {
 	assert.Equal(t, 0, n)
 	assert.False(t, done)
 
+	// Test: Whitespace before the colon
+	headers = NewHeaders()
+	data = []byte("Content-Length\t: 5\r\n\r\n")
+	_, _, err = headers.Parse(data)
+	require.ErrorIs(t, err, ErrWhitespaceBeforeColon)
+
 	// Test: Invalid character in field name
 	headers = NewHeaders()
 	data = []byte("H©st: localhost:42069\r\n\r\n")
@@ -78,3 +89,331 @@ func TestHeaders(t *testing.T) {
 	assert.Equal(t, 0, n)
 	assert.False(t, done)
 }
+
+func TestFieldValues(t *testing.T) {
+	// Test: Real browser headers with internal whitespace
+	headers := NewHeaders()
+	data := []byte("User-Agent: Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0\r\n" +
+		"Accept: text/html, application/json;q=0.9, */*;q=0.8\r\n" +
+		"Accept-Language: en-US,en;q=0.5\r\n" +
+		"Cookie: a=1; b=2\r\n" +
+		"\r\n")
+	total := 0
+	for {
+		n, done, err := headers.Parse(data[total:])
+		require.NoError(t, err)
+		total += n
+		if done {
+			break
+		}
+	}
+	assert.Equal(t, len(data), total)
+	assert.Equal(t, "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0", get(headers, "user-agent"))
+	assert.Equal(t, "text/html, application/json;q=0.9, */*;q=0.8", get(headers, "accept"))
+	assert.Equal(t, "en-US,en;q=0.5", get(headers, "accept-language"))
+	assert.Equal(t, "a=1; b=2", get(headers, "cookie"))
+
+	// Test: Optional whitespace is trimmed on both sides
+	headers = NewHeaders()
+	data = []byte("X-Padded:\t  padded value \t\r\n\r\n")
+	n, done, err := headers.Parse(data)
+	require.NoError(t, err)
+	assert.Equal(t, "padded value", get(headers, "x-padded"))
+	assert.Equal(t, 28, n)
+	assert.False(t, done)
+
+	// Test: No whitespace after the colon
+	headers = NewHeaders()
+	data = []byte("Host:localhost:42069\r\n\r\n")
+	_, _, err = headers.Parse(data)
+	require.NoError(t, err)
+	assert.Equal(t, "localhost:42069", get(headers, "host"))
+
+	// Test: Empty value
+	headers = NewHeaders()
+	data = []byte("X-Empty:   \r\n\r\n")
+	_, _, err = headers.Parse(data)
+	require.NoError(t, err)
+	assert.Equal(t, "", get(headers, "x-empty"))
+
+	// Test: obs-text is tolerated
+	headers = NewHeaders()
+	data = []byte("X-Name: caf\xe9\r\n\r\n")
+	_, _, err = headers.Parse(data)
+	require.NoError(t, err)
+	assert.Equal(t, "caf\xe9", get(headers, "x-name"))
+
+	// Test: Control characters are rejected
+	headers = NewHeaders()
+	data = []byte("X-Bad: a\x00b\r\n\r\n")
+	n, _, err = headers.Parse(data)
+	require.ErrorIs(t, err, ErrMalformedFieldValue)
+	assert.Equal(t, 0, n)
+
+	// Test: Bare LF is rejected
+	headers = NewHeaders()
+	data = []byte("X-Bad: a\nInjected: b\r\n\r\n")
+	_, _, err = headers.Parse(data)
+	require.ErrorIs(t, err, ErrMalformedFieldValue)
+
+	// Test: Bare CR is rejected
+	headers = NewHeaders()
+	data = []byte("X-Bad: a\rb\r\n\r\n")
+	_, _, err = headers.Parse(data)
+	require.ErrorIs(t, err, ErrMalformedFieldValue)
+
+	// Test: DEL is rejected
+	headers = NewHeaders()
+	data = []byte("X-Bad: a\x7fb\r\n\r\n")
+	_, _, err = headers.Parse(data)
+	require.ErrorIs(t, err, ErrMalformedFieldValue)
+
+	// Test: Line without a colon
+	headers = NewHeaders()
+	data = []byte("NoColonHere\r\n\r\n")
+	_, _, err = headers.Parse(data)
+	require.ErrorIs(t, err, ErrMalformedFieldName)
+
+	// Test: Empty field-name
+	headers = NewHeaders()
+	data = []byte(": value\r\n\r\n")
+	_, _, err = headers.Parse(data)
+	require.ErrorIs(t, err, ErrMalformedFieldName)
+}
+
+func TestHeadersMultiValue(t *testing.T) {
+	// Test: Duplicate fields keep separate values and original casing
+	headers := NewHeaders()
+	data := []byte("Set-Cookie: a=1\r\nHost: localhost:42069\r\nset-cookie: b=2\r\n\r\n")
+	total := 0
+	for {
+		n, done, err := headers.Parse(data[total:])
+		require.NoError(t, err)
+		total += n
+		if done {
+			break
+		}
+	}
+	assert.Equal(t, []string{"a=1", "b=2"}, headers.Values("SET-COOKIE"))
+	assert.Equal(t, "a=1,b=2", get(headers, "Set-Cookie"))
+	assert.Equal(t, 3, headers.Len())
+
+	var names []string
+	for name := range headers.All() {
+		names = append(names, name)
+	}
+	assert.Equal(t, []string{"Set-Cookie", "Host", "set-cookie"}, names)
+
+	// Test: Set replaces every value in place of the first one
+	headers.Set("set-COOKIE", "c=3")
+	assert.Equal(t, []string{"c=3"}, headers.Values("Set-Cookie"))
+	names = nil
+	for name := range headers.All() {
+		names = append(names, name)
+	}
+	assert.Equal(t, []string{"set-COOKIE", "Host"}, names)
+
+	// Test: Set appends a missing field
+	headers.Set("Content-Type", "text/plain")
+	assert.Equal(t, "text/plain", get(headers, "content-type"))
+	assert.Equal(t, 3, headers.Len())
+
+	// Test: Del removes every value
+	headers.Add("X-Tag", "one")
+	headers.Add("X-Tag", "two")
+	headers.Del("x-tag")
+	assert.Empty(t, headers.Values("X-Tag"))
+	_, err := headers.Get("X-Tag")
+	require.ErrorIs(t, err, ErrFieldNameNotFound)
+
+	// Test: Zero value is ready to use
+	var zero Headers
+	zero.Add("Host", "localhost")
+	assert.Equal(t, "localhost", get(zero, "host"))
+
+	// Test: Copies share their fields
+	h := NewHeaders()
+	h.Add("Host", "localhost")
+	cp := h
+	cp.Add("X-Copy", "1")
+	h.Add("X-Orig", "2")
+	assert.Equal(t, "1", get(h, "x-copy"))
+	assert.Equal(t, "2", get(cp, "x-orig"))
+	assert.Equal(t, 3, cp.Len())
+	cp.Del("host")
+	assert.Empty(t, h.Values("host"))
+
+	// Test: A clone does not
+	c := h.Clone()
+	c.Set("X-Copy", "changed")
+	h.Add("X-Orig", "3")
+	assert.Equal(t, "1", get(h, "x-copy"))
+	assert.Equal(t, []string{"2"}, c.Values("x-orig"))
+}
+
+func TestValidate(t *testing.T) {
+	// Test: Valid fields
+	h := NewHeaders()
+	h.Add("Location", "/next")
+	h.Add("X-Tab", "a\tb")
+	require.NoError(t, h.Validate())
+
+	// Test: Invalid fields
+	testCases := []struct {
+		name  string
+		value string
+		err   error
+	}{
+		{"", "x", ErrMalformedFieldName},
+		{"X Bad", "x", ErrMalformedFieldName},
+		{"X-Bad\r\nX-Injected", "x", ErrMalformedFieldName},
+		{"Location", "/\r\nSet-Cookie: a=1", ErrMalformedFieldValue},
+		{"Location", "/\n", ErrMalformedFieldValue},
+		{"Location", "/\x00", ErrMalformedFieldValue},
+	}
+	for _, tc := range testCases {
+		h := NewHeaders()
+		h.Add(tc.name, tc.value)
+		assert.ErrorIs(t, h.Validate(), tc.err, tc.name)
+	}
+}
+
+func TestParsedValuesStayIntact(t *testing.T) {
+	// Test: Parsing into a copy does not overwrite values handed out
+	h := NewHeaders()
+	_, _, err := h.Parse([]byte("Host: localhost\r\n"))
+	require.NoError(t, err)
+	c := h
+	_, _, err = c.Parse([]byte("X-Copy: copy\r\n"))
+	require.NoError(t, err)
+	copied := get(c, "x-copy")
+	_, _, err = h.Parse([]byte("X-Original: original\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "localhost", get(h, "host"))
+	assert.Equal(t, "original", get(h, "x-original"))
+	assert.Equal(t, "copy", copied)
+	assert.Equal(t, "copy", get(c, "x-copy"))
+	assert.Equal(t, "copy", get(h, "x-copy"))
+	assert.Equal(t, "original", get(c, "x-original"))
+	assert.Equal(t, 3, c.Len())
+
+	// Test: A clone and its original are parsed into independently
+	h = NewHeaders()
+	_, _, err = h.Parse([]byte("Host: localhost\r\n"))
+	require.NoError(t, err)
+	c = h.Clone()
+	_, _, err = c.Parse([]byte("X-Copy: copy\r\n"))
+	require.NoError(t, err)
+	_, _, err = h.Parse([]byte("X-Original: original\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "localhost", get(h, "host"))
+	assert.Equal(t, "original", get(h, "x-original"))
+	assert.Equal(t, "", get(h, "x-copy"))
+	assert.Equal(t, "localhost", get(c, "host"))
+	assert.Equal(t, "copy", get(c, "x-copy"))
+	assert.Equal(t, "", get(c, "x-original"))
+	assert.Equal(t, 2, h.Len())
+	assert.Equal(t, 2, c.Len())
+
+	// Test: Fields beyond the arena are still parsed
+	h = NewHeaders()
+	long := strings.Repeat("a", 3000)
+	for i := 0; i < 3; i++ {
+		_, _, err = h.Parse([]byte("X-Long: " + long + "\r\n"))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []string{long, long, long}, h.Values("x-long"))
+
+	// Test: Parsed values do not depend on the input buffer
+	h = NewHeaders()
+	data := []byte("Host: localhost\r\n")
+	_, _, err = h.Parse(data)
+	require.NoError(t, err)
+	copy(data, "XXXXXXXXXXXXXXX")
+	assert.Equal(t, "localhost", get(h, "Host"))
+}
+
+func BenchmarkParse(b *testing.B) {
+	data := []byte("Host: localhost:42069\r\n" +
+		"User-Agent: curl/7.81.0\r\n" +
+		"Accept: */*\r\n" +
+		"Accept-Encoding: gzip, deflate\r\n" +
+		"\r\n")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		h := NewHeaders()
+		rest := data
+		for {
+			n, done, err := h.Parse(rest)
+			if err != nil {
+				b.Fatal(err)
+			}
+			rest = rest[n:]
+			if done {
+				break
+			}
+		}
+	}
+}
+
+func BenchmarkGet(b *testing.B) {
+	h := NewHeaders()
+	h.Add("Host", "localhost:42069")
+	h.Add("User-Agent", "curl/7.81.0")
+	h.Add("Accept", "*/*")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, err := h.Get("accept")
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	f.Add([]byte("Host: localhost:42069\r\n\r\n"))
+	f.Add([]byte("       Host: localhost:42069       \r\n\r\n"))
+	f.Add([]byte("       Host : localhost:42069       \r\n\r\n"))
+	f.Add([]byte("NoColonHere\r\n\r\n"))
+	f.Add([]byte(":\r\n"))
+	f.Add([]byte("H\xa9st: localhost:42069\r\n\r\n"))
+	f.Add([]byte("X-Value: a\x7fb\r\n"))
+	f.Add([]byte("X-Value: \xe9t\xe9\r\n"))
+	f.Add([]byte("\r\n"))
+	f.Add([]byte("Host: localhost"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		h := NewHeaders()
+		rest := data
+		for {
+			n, done, err := h.Parse(rest)
+			if err != nil {
+				return
+			}
+			if n < 0 || n > len(rest) {
+				t.Fatalf("consumed %d bytes of %d", n, len(rest))
+			}
+			if done || n == 0 {
+				break
+			}
+			rest = rest[n:]
+		}
+
+		for name, value := range h.All() {
+			if name == "" {
+				t.Fatalf("empty field-name parsed from %q", data)
+			}
+			for i := 0; i < len(name); i++ {
+				if !IsTokenChar(name[i]) {
+					t.Fatalf("field-name %q is not a token", name)
+				}
+			}
+			if strings.ContainsAny(value, "\r\n\x00") {
+				t.Fatalf("field-value %q contains a control character", value)
+			}
+			if strings.Trim(value, OWS) != value {
+				t.Fatalf("field-value %q is not trimmed", value)
+			}
+		}
+	})
+}
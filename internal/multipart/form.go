@@ -0,0 +1,132 @@
+package multipart
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/Dawid-Klos/httpfromtcp/internal/headers"
+)
+
+// Form is a parsed multipart form. Call RemoveAll once the files are no longer
+// needed to delete any temporary files.
+type Form struct {
+	Value map[string][]string
+	File  map[string][]*FileHeader
+}
+
+// FileHeader describes a file part of a form.
+type FileHeader struct {
+	Filename string
+	Headers  headers.Headers
+	Size     int64
+
+	content []byte
+	tmpfile string
+}
+
+// ReadForm reads every part of the body. Parts with a filename are stored in
+// File, all others in Value. File content above Limits.MaxMemoryBytes is
+// written to a temporary file.
+func (r *Reader) ReadForm() (*Form, error) {
+	form := &Form{
+		Value: make(map[string][]string),
+		File:  make(map[string][]*FileHeader),
+	}
+
+	for {
+		part, err := r.NextPart()
+		if errors.Is(err, io.EOF) {
+			return form, nil
+		}
+		if err != nil {
+			form.RemoveAll()
+			return nil, err
+		}
+
+		name := part.FormName()
+		if name == "" {
+			continue
+		}
+
+		filename := part.FileName()
+		if filename == "" {
+			value, err := io.ReadAll(part)
+			if err != nil {
+				form.RemoveAll()
+				return nil, err
+			}
+			form.Value[name] = append(form.Value[name], string(value))
+			continue
+		}
+
+		fh, err := r.readFile(part, filename)
+		if err != nil {
+			form.RemoveAll()
+			return nil, err
+		}
+		form.File[name] = append(form.File[name], fh)
+	}
+}
+
+// readFile keeps a file part in memory until it grows past MaxMemoryBytes,
+// then moves it to a temporary file.
+func (r *Reader) readFile(part *Part, filename string) (*FileHeader, error) {
+	fh := &FileHeader{Filename: filename, Headers: part.Headers}
+
+	var buf bytes.Buffer
+	var src io.Reader = part
+	if r.Limits.MaxMemoryBytes > 0 {
+		src = io.LimitReader(part, r.Limits.MaxMemoryBytes+1)
+	}
+	n, err := io.Copy(&buf, src)
+	if err != nil {
+		return nil, err
+	}
+	if r.Limits.MaxMemoryBytes <= 0 || n <= r.Limits.MaxMemoryBytes {
+		fh.content = buf.Bytes()
+		fh.Size = n
+		return fh, nil
+	}
+
+	file, err := os.CreateTemp("", "multipart-")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	size, err := io.Copy(file, io.MultiReader(&buf, part))
+	if err != nil {
+		os.Remove(file.Name())
+		return nil, err
+	}
+	fh.tmpfile = file.Name()
+	fh.Size = size
+	return fh, nil
+}
+
+// Open opens the file content for reading.
+func (fh *FileHeader) Open() (io.ReadCloser, error) {
+	if fh.tmpfile != "" {
+		return os.Open(fh.tmpfile)
+	}
+	return io.NopCloser(bytes.NewReader(fh.content)), nil
+}
+
+// RemoveAll deletes the temporary files backing the form.
+func (f *Form) RemoveAll() error {
+	var err error
+	for _, files := range f.File {
+		for _, fh := range files {
+			if fh.tmpfile == "" {
+				continue
+			}
+			removeErr := os.Remove(fh.tmpfile)
+			if removeErr != nil && !errors.Is(removeErr, os.ErrNotExist) && err == nil {
+				err = removeErr
+			}
+		}
+	}
+	return err
+}
@@ -0,0 +1,312 @@
+// Package multipart reads multipart/form-data bodies as sent by HTML forms.
+//
+// A Reader walks the parts of a body one at a time without buffering it: each
+// Part exposes its header fields, parsed by the headers package, and reads its
+// content up to the next boundary. ReadForm builds on the Reader to collect a
+// whole form, keeping values in memory and spilling large files to temporary
+// files on disk. Limits bound the size of every part and of the body in total.
+package multipart
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"path"
+	"strings"
+
+	"github.com/Dawid-Klos/httpfromtcp/internal/headers"
+)
+
+// maxBoundaryLength is the longest boundary RFC 2046 allows.
+const maxBoundaryLength = 70
+
+const bufferSize = 4096
+
+// Limits bounds what a form may contain. A zero field means no limit.
+type Limits struct {
+	// MaxPartHeaderBytes bounds the header section of each part, including
+	// line terminators.
+	MaxPartHeaderBytes int
+	// MaxPartBytes bounds the content of each part.
+	MaxPartBytes int64
+	// MaxTotalBytes bounds the content of all parts together.
+	MaxTotalBytes int64
+	// MaxMemoryBytes is how much of a file part ReadForm keeps in memory.
+	// Larger files are written to a temporary file instead.
+	MaxMemoryBytes int64
+}
+
+var DefaultLimits = Limits{
+	MaxPartHeaderBytes: 8 << 10,
+	MaxPartBytes:       10 << 20,
+	MaxTotalBytes:      32 << 20,
+	MaxMemoryBytes:     1 << 20,
+}
+
+var ErrMalformedBoundary = errors.New("malformed multipart boundary")
+var ErrMalformedPart = errors.New("malformed multipart part")
+var ErrPartHeadersTooLarge = errors.New("multipart part header section too large")
+var ErrPartTooLarge = errors.New("multipart part too large")
+var ErrFormTooLarge = errors.New("multipart form too large")
+
+// Reader reads the parts of a multipart body in order.
+type Reader struct {
+	Limits Limits
+
+	br             *bufio.Reader
+	dashBoundary   []byte
+	nlDashBoundary []byte
+	current        *Part
+	started        bool
+	finished       bool
+	totalBytes     int64
+}
+
+// Part is a single part of a multipart body. Reading from it yields the part
+// content, ending with io.EOF at the next boundary.
+type Part struct {
+	Headers headers.Headers
+
+	reader *Reader
+	bytes  int64
+	done   bool
+}
+
+// NewReader returns a Reader for the multipart body read from r, whose parts
+// are separated by boundary. It uses DefaultLimits.
+func NewReader(r io.Reader, boundary string) (*Reader, error) {
+	if !validBoundary(boundary) {
+		return nil, ErrMalformedBoundary
+	}
+	return &Reader{
+		Limits:         DefaultLimits,
+		br:             bufio.NewReaderSize(r, bufferSize),
+		dashBoundary:   []byte("--" + boundary),
+		nlDashBoundary: []byte("\r\n--" + boundary),
+	}, nil
+}
+
+// NextPart returns the next part of the body, discarding whatever is left of
+// the current one. It returns io.EOF after the closing boundary.
+func (r *Reader) NextPart() (*Part, error) {
+	if r.finished {
+		return nil, io.EOF
+	}
+	if r.current != nil {
+		_, err := io.Copy(io.Discard, r.current)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var err error
+	if !r.started {
+		err = r.skipPreamble()
+	} else {
+		err = r.finishBoundaryLine()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if r.finished {
+		return nil, io.EOF
+	}
+
+	part := &Part{Headers: headers.NewHeaders(), reader: r}
+	err = r.readPartHeaders(part)
+	if err != nil {
+		return nil, err
+	}
+	r.current = part
+	return part, nil
+}
+
+// skipPreamble discards everything before the first boundary line.
+func (r *Reader) skipPreamble() error {
+	r.started = true
+	midLine := false
+	for {
+		line, err := r.br.ReadSlice('\n')
+		if errors.Is(err, bufio.ErrBufferFull) {
+			midLine = true
+			continue
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+
+		isBoundary := !midLine && bytes.HasPrefix(line, r.dashBoundary)
+		midLine = false
+		if isBoundary {
+			switch string(bytes.TrimRight(line[len(r.dashBoundary):], " \t")) {
+			case "\r\n":
+				return nil
+			case "--", "--\r\n":
+				r.finished = true
+				return nil
+			}
+		}
+		if err != nil {
+			return io.ErrUnexpectedEOF
+		}
+	}
+}
+
+// finishBoundaryLine reads what follows a delimiter: "--" for the closing
+// boundary, or optional whitespace and the line break before the next part.
+// Anything after the closing boundary is an epilogue and is ignored.
+func (r *Reader) finishBoundaryLine() error {
+	line, err := r.br.ReadSlice('\n')
+	if bytes.HasPrefix(line, []byte("--")) {
+		r.finished = true
+		return nil
+	}
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+
+	if !bytes.Equal(bytes.TrimLeft(line, " \t"), []byte("\r\n")) {
+		return ErrMalformedPart
+	}
+	return nil
+}
+
+func (r *Reader) readPartHeaders(part *Part) error {
+	headerBytes := 0
+	for {
+		line, err := r.br.ReadSlice('\n')
+		if errors.Is(err, bufio.ErrBufferFull) {
+			return ErrPartHeadersTooLarge
+		}
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+
+		headerBytes += len(line)
+		if r.Limits.MaxPartHeaderBytes > 0 && headerBytes > r.Limits.MaxPartHeaderBytes {
+			return ErrPartHeadersTooLarge
+		}
+
+		n, done, err := part.Headers.Parse(line)
+		if err != nil {
+			return err
+		}
+		if n != len(line) {
+			// The line does not end in CRLF.
+			return ErrMalformedPart
+		}
+		if done {
+			return nil
+		}
+	}
+}
+
+func (r *Reader) count(n int, part *Part) error {
+	part.bytes += int64(n)
+	r.totalBytes += int64(n)
+	if r.Limits.MaxPartBytes > 0 && part.bytes > r.Limits.MaxPartBytes {
+		return ErrPartTooLarge
+	}
+	if r.Limits.MaxTotalBytes > 0 && r.totalBytes > r.Limits.MaxTotalBytes {
+		return ErrFormTooLarge
+	}
+	return nil
+}
+
+// Read reads the part content. Data is only returned once it is certain not
+// to be the start of the next boundary.
+func (p *Part) Read(b []byte) (int, error) {
+	if p.done {
+		return 0, io.EOF
+	}
+	r := p.reader
+	delim := r.nlDashBoundary
+
+	buf, err := r.br.Peek(bufferSize)
+	if i := bytes.Index(buf, delim); i != -1 {
+		n := copy(b, buf[:i])
+		r.br.Discard(n)
+		if n == i {
+			r.br.Discard(len(delim))
+			p.done = true
+		}
+		if countErr := r.count(n, p); countErr != nil {
+			return n, countErr
+		}
+		if n == 0 {
+			return 0, io.EOF
+		}
+		return n, nil
+	}
+	if err != nil {
+		return 0, unexpectedEOF(err)
+	}
+
+	// The end of the buffer may hold the start of the delimiter.
+	safe := len(buf) - len(delim) + 1
+	n := copy(b, buf[:safe])
+	r.br.Discard(n)
+	return n, r.count(n, p)
+}
+
+// FormName returns the name parameter of the form-data Content-Disposition,
+// or "" if there is none.
+func (p *Part) FormName() string {
+	params := p.dispositionParams()
+	return params["name"]
+}
+
+// FileName returns the filename parameter of the form-data
+// Content-Disposition, or "" for a part that is not a file. Only the last
+// element of the name is kept, so a client cannot smuggle a path.
+func (p *Part) FileName() string {
+	params := p.dispositionParams()
+	filename, ok := params["filename"]
+	if !ok || filename == "" {
+		return ""
+	}
+	filename = path.Base(strings.ReplaceAll(filename, "\\", "/"))
+	if filename == "." || filename == ".." || filename == "/" {
+		return ""
+	}
+	return filename
+}
+
+func (p *Part) dispositionParams() map[string]string {
+	disposition, err := p.Headers.Get("Content-Disposition")
+	if err != nil {
+		return nil
+	}
+	dispositionType, params, err := mime.ParseMediaType(disposition)
+	if err != nil || dispositionType != "form-data" {
+		return nil
+	}
+	return params
+}
+
+// validBoundary checks the boundary against RFC 2046 section 5.1.1.
+func validBoundary(boundary string) bool {
+	if boundary == "" || len(boundary) > maxBoundaryLength {
+		return false
+	}
+	if strings.HasSuffix(boundary, " ") {
+		return false
+	}
+	for i := 0; i < len(boundary); i++ {
+		c := boundary[i]
+		isAlnum := 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+		if !isAlnum && !strings.ContainsRune("'()+_,-./:=? ", rune(c)) {
+			return false
+		}
+	}
+	return true
+}
+
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
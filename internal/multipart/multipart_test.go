@@ -0,0 +1,176 @@
+package multipart
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const boundary = "----formboundary42"
+
+// body builds a multipart body from parts, each given as its header section
+// and content.
+func body(parts ...[2]string) string {
+	var b strings.Builder
+	b.WriteString("preamble to ignore\r\n")
+	for _, part := range parts {
+		b.WriteString("--" + boundary + "\r\n")
+		b.WriteString(part[0] + "\r\n\r\n")
+		b.WriteString(part[1] + "\r\n")
+	}
+	b.WriteString("--" + boundary + "--\r\n")
+	b.WriteString("epilogue to ignore")
+	return b.String()
+}
+
+func TestReader(t *testing.T) {
+	raw := body(
+		[2]string{`Content-Disposition: form-data; name="title"`, "Hello\r\nworld"},
+		[2]string{"Content-Disposition: form-data; name=\"upload\"; filename=\"../../notes.txt\"\r\nContent-Type: text/plain", "--" + boundary + "x is not a boundary"},
+		[2]string{`Content-Disposition: form-data; name="empty"`, ""},
+	)
+
+	// Test: Parts are read in order with their headers
+	r, err := NewReader(iotest.OneByteReader(strings.NewReader(raw)), boundary)
+	require.NoError(t, err)
+	part, err := r.NextPart()
+	require.NoError(t, err)
+	assert.Equal(t, "title", part.FormName())
+	assert.Equal(t, "", part.FileName())
+	content, err := io.ReadAll(part)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello\r\nworld", string(content))
+
+	part, err = r.NextPart()
+	require.NoError(t, err)
+	assert.Equal(t, "upload", part.FormName())
+	assert.Equal(t, "notes.txt", part.FileName())
+	contentType, err := part.Headers.Get("content-type")
+	require.NoError(t, err)
+	assert.Equal(t, "text/plain", contentType)
+	content, err = io.ReadAll(part)
+	require.NoError(t, err)
+	assert.Equal(t, "--"+boundary+"x is not a boundary", string(content))
+
+	part, err = r.NextPart()
+	require.NoError(t, err)
+	content, err = io.ReadAll(part)
+	require.NoError(t, err)
+	assert.Empty(t, content)
+
+	_, err = r.NextPart()
+	assert.ErrorIs(t, err, io.EOF)
+
+	// Test: Unread parts are skipped
+	r, err = NewReader(strings.NewReader(raw), boundary)
+	require.NoError(t, err)
+	names := []string{}
+	for {
+		part, err := r.NextPart()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, part.FormName())
+	}
+	assert.Equal(t, []string{"title", "upload", "empty"}, names)
+
+	// Test: Missing closing boundary
+	r, err = NewReader(strings.NewReader("--"+boundary+"\r\nContent-Disposition: form-data; name=\"a\"\r\n\r\nvalue"), boundary)
+	require.NoError(t, err)
+	part, err = r.NextPart()
+	require.NoError(t, err)
+	_, err = io.ReadAll(part)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	// Test: Malformed part header
+	r, err = NewReader(strings.NewReader(body([2]string{"Content-Disposition form-data", "value"})), boundary)
+	require.NoError(t, err)
+	_, err = r.NextPart()
+	assert.Error(t, err)
+
+	// Test: Invalid boundary
+	_, err = NewReader(strings.NewReader(raw), "")
+	assert.ErrorIs(t, err, ErrMalformedBoundary)
+	_, err = NewReader(strings.NewReader(raw), strings.Repeat("b", 71))
+	assert.ErrorIs(t, err, ErrMalformedBoundary)
+}
+
+func TestLimits(t *testing.T) {
+	raw := body(
+		[2]string{`Content-Disposition: form-data; name="a"`, strings.Repeat("a", 100)},
+		[2]string{`Content-Disposition: form-data; name="b"`, strings.Repeat("b", 100)},
+	)
+
+	// Test: Part too large
+	r, err := NewReader(strings.NewReader(raw), boundary)
+	require.NoError(t, err)
+	r.Limits.MaxPartBytes = 50
+	_, err = r.ReadForm()
+	assert.ErrorIs(t, err, ErrPartTooLarge)
+
+	// Test: Form too large
+	r, err = NewReader(strings.NewReader(raw), boundary)
+	require.NoError(t, err)
+	r.Limits.MaxTotalBytes = 150
+	_, err = r.ReadForm()
+	assert.ErrorIs(t, err, ErrFormTooLarge)
+
+	// Test: Part header section too large
+	r, err = NewReader(strings.NewReader(raw), boundary)
+	require.NoError(t, err)
+	r.Limits.MaxPartHeaderBytes = 20
+	_, err = r.NextPart()
+	assert.ErrorIs(t, err, ErrPartHeadersTooLarge)
+}
+
+func TestReadForm(t *testing.T) {
+	small := "small file"
+	large := strings.Repeat("0123456789", 100)
+	raw := body(
+		[2]string{`Content-Disposition: form-data; name="title"`, "hello"},
+		[2]string{`Content-Disposition: form-data; name="title"`, "again"},
+		[2]string{`Content-Disposition: form-data; name="files"; filename="small.txt"`, small},
+		[2]string{`Content-Disposition: form-data; name="files"; filename="large.txt"`, large},
+	)
+
+	// Test: Values and files, with large files spilled to disk
+	r, err := NewReader(strings.NewReader(raw), boundary)
+	require.NoError(t, err)
+	r.Limits.MaxMemoryBytes = 100
+	form, err := r.ReadForm()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hello", "again"}, form.Value["title"])
+	require.Len(t, form.File["files"], 2)
+
+	fh := form.File["files"][0]
+	assert.Equal(t, "small.txt", fh.Filename)
+	assert.Equal(t, int64(len(small)), fh.Size)
+	assert.Empty(t, fh.tmpfile)
+	f, err := fh.Open()
+	require.NoError(t, err)
+	content, err := io.ReadAll(f)
+	require.NoError(t, err)
+	assert.Equal(t, small, string(content))
+
+	fh = form.File["files"][1]
+	assert.Equal(t, "large.txt", fh.Filename)
+	assert.Equal(t, int64(len(large)), fh.Size)
+	require.NotEmpty(t, fh.tmpfile)
+	f, err = fh.Open()
+	require.NoError(t, err)
+	content, err = io.ReadAll(f)
+	require.NoError(t, err)
+	f.Close()
+	assert.Equal(t, large, string(content))
+
+	// Test: RemoveAll deletes the temporary files
+	require.NoError(t, form.RemoveAll())
+	_, err = os.Stat(fh.tmpfile)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
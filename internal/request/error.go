@@ -0,0 +1,60 @@
+package request
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ParseError is returned by a Reader when a request cannot be parsed. It
+// wraps the underlying sentinel error, so errors.Is still matches it, and
+// suggests the status code a server should reply with before closing the
+// connection.
+type ParseError struct {
+	// StatusCode is the suggested response status: 400, 408, 413, 414, 431,
+	// 501 or 505.
+	StatusCode int
+	// State is the parser state the error occurred in.
+	State string
+	// Offset is the number of bytes of the request parsed successfully before
+	// the error.
+	Offset int64
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%v (state: %s, offset: %d)", e.Err, e.State, e.Offset)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+func newParseError(request *Request, offset int64, err error) *ParseError {
+	return &ParseError{
+		StatusCode: statusForError(err),
+		State:      string(request.state),
+		Offset:     offset,
+		Err:        err,
+	}
+}
+
+// statusForError picks the status code for rejecting a request that failed
+// with err.
+func statusForError(err error) int {
+	switch {
+	case isTimeout(err):
+		return 408
+	case errors.Is(err, ErrRequestLineTooLong):
+		return 414
+	case errors.Is(err, ErrHeadersTooLarge):
+		return 431
+	case errors.Is(err, ErrBodyTooLarge):
+		return 413
+	case errors.Is(err, ErrUnsupportedTransferEncoding):
+		return 501
+	case errors.Is(err, ErrUnsuppportedHTTPVersion):
+		return 505
+	default:
+		return 400
+	}
+}
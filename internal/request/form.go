@@ -0,0 +1,63 @@
+package request
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+
+	"github.com/Dawid-Klos/httpfromtcp/internal/multipart"
+)
+
+// maxFormBytes bounds the urlencoded body ParseForm reads into memory.
+const maxFormBytes = 10 << 20
+
+var ErrNotURLEncodedForm = errors.New("Content-Type is not application/x-www-form-urlencoded")
+var ErrNotMultipartForm = errors.New("Content-Type is not multipart/form-data")
+
+// ParseForm parses an application/x-www-form-urlencoded body into its
+// parameters. The body is consumed through BodyReader, so ParseForm works for
+// streamed requests too. Query parameters are not included; use URL.Query.
+func (r *Request) ParseForm() (Values, error) {
+	mediaType, _, err := r.contentType()
+	if err != nil || mediaType != "application/x-www-form-urlencoded" {
+		return nil, ErrNotURLEncodedForm
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.body(), maxFormBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxFormBytes {
+		return nil, ErrBodyTooLarge
+	}
+	return ParseQuery(string(body))
+}
+
+// MultipartReader returns a reader over the parts of a multipart/form-data
+// body, which lets a handler process uploads as they arrive. Use its ReadForm
+// method to collect the whole form instead.
+func (r *Request) MultipartReader() (*multipart.Reader, error) {
+	mediaType, params, err := r.contentType()
+	if err != nil || mediaType != "multipart/form-data" {
+		return nil, ErrNotMultipartForm
+	}
+	return multipart.NewReader(r.body(), params["boundary"])
+}
+
+func (r *Request) contentType() (string, map[string]string, error) {
+	contentType, err := r.Headers.Get("Content-Type")
+	if err != nil {
+		return "", nil, err
+	}
+	return mime.ParseMediaType(contentType)
+}
+
+// body returns the reader for the request body, falling back to Body for
+// requests that were built by hand.
+func (r *Request) body() io.Reader {
+	if r.BodyReader != nil {
+		return r.BodyReader
+	}
+	return bytes.NewReader(r.Body)
+}
@@ -0,0 +1,60 @@
+package request
+
+// Parser parses a single request from data pushed into it, for callers that
+// do their own I/O such as event loops. Unlike a Reader it never blocks and
+// never reads on its own.
+//
+// Feed the parser whatever has been received; it consumes complete elements
+// and leaves the rest. Unconsumed bytes must be fed again, followed by the
+// data that arrives next. Once Done reports true, bytes left over belong to
+// the next request on the connection.
+type Parser struct {
+	request *Request
+	err     error
+}
+
+// NewParser returns a Parser for one request, bounded by limits.
+func NewParser(limits Limits) *Parser {
+	return &Parser{request: newRequest(limits)}
+}
+
+// Feed parses as much of data as possible and returns the number of bytes
+// consumed. A request that cannot be parsed is reported as a *ParseError,
+// after which every call fails with the same error. Feeding a done parser
+// returns ErrReadingDataInDoneState.
+func (p *Parser) Feed(data []byte) (int, error) {
+	if p.err != nil {
+		return 0, p.err
+	}
+	if p.request.done() {
+		return 0, ErrReadingDataInDoneState
+	}
+
+	n, err := p.request.parse(data)
+	if err != nil {
+		p.err = newParseError(p.request, p.request.parsedBytes, err)
+		return 0, p.err
+	}
+	if p.request.done() {
+		p.request.setBufferedBody()
+	}
+	return n, nil
+}
+
+// HeadersDone reports whether the request line and header section have been
+// parsed, so that the request can be inspected before its body arrives.
+func (p *Parser) HeadersDone() bool {
+	return p.request.headersDone()
+}
+
+// Done reports whether the whole request, including its body, is parsed.
+func (p *Parser) Done() bool {
+	return p.request.done()
+}
+
+// Request returns the request parsed so far. Its fields fill in as parsing
+// advances: Body holds the part of the body decoded so far, and is complete
+// once Done reports true.
+func (p *Parser) Request() *Request {
+	return p.request
+}
@@ -0,0 +1,204 @@
+package request
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// initialBufferSize is the size of a Reader's buffer before it grows to fit
+// longer lines.
+const initialBufferSize = 512
+
+// bufferPool recycles the initial buffers of Readers, which only hold bytes
+// until the parser has copied them out.
+var bufferPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, initialBufferSize)
+		return &buf
+	},
+}
+
+var ErrBodyClosed = errors.New("read on closed body")
+
+// Reader parses successive requests from a single connection. Bytes read past
+// the end of one request are kept and used as the start of the next one, so
+// pipelined requests are not lost.
+type Reader struct {
+	// Limits bounds each request read. It defaults to DefaultLimits.
+	Limits Limits
+	// StreamBody makes ReadRequest return as soon as the header section is
+	// parsed. The body is then decoded lazily through Request.BodyReader and
+	// must be consumed or closed before the next request is read.
+	StreamBody bool
+	// HeaderTimeout bounds the time from the start of ReadRequest until the
+	// header section is received. Zero means no timeout.
+	HeaderTimeout time.Duration
+	// BodyTimeout bounds the time from the first read of the body until it
+	// is received in full. Zero means no timeout.
+	BodyTimeout time.Duration
+	// MinBodyRate is the slowest body transfer rate accepted, in bytes per
+	// second. A client gets one second of slack, after which the body has to
+	// keep up with the rate. Zero means any rate is accepted.
+	MinBodyRate int64
+
+	reader      io.Reader
+	buf         []byte
+	pooled      *[]byte
+	bufIdx      int
+	pending     *Request
+	deadlineSet bool
+}
+
+func NewReader(reader io.Reader) *Reader {
+	pooled := bufferPool.Get().(*[]byte)
+	return &Reader{
+		Limits: DefaultLimits,
+		reader: reader,
+		buf:    *pooled,
+		pooled: pooled,
+	}
+}
+
+// Release hands the Reader's buffer back for reuse by other Readers. Call it
+// once the connection is done; the Reader must not be used afterwards.
+func (r *Reader) Release() {
+	if r.pooled != nil {
+		bufferPool.Put(r.pooled)
+		r.pooled = nil
+	}
+	r.buf = nil
+	r.bufIdx = 0
+}
+
+// ReadRequest parses the next request from the connection. It returns io.EOF
+// if the connection is closed cleanly before any byte of a new request has
+// been received. A request that cannot be parsed is reported as a
+// *ParseError; errors reading from the connection are returned as is.
+func (r *Reader) ReadRequest() (*Request, error) {
+	return r.ReadRequestContext(context.Background())
+}
+
+// ReadRequestContext is ReadRequest that gives up when ctx is done. For a
+// streamed body ctx also applies to reads through Request.BodyReader.
+//
+// When the underlying reader has a SetReadDeadline method, as a net.Conn
+// does, the context and the timeouts interrupt a blocked read. Otherwise they
+// are only checked between reads.
+func (r *Reader) ReadRequestContext(ctx context.Context) (*Request, error) {
+	if r.pending != nil {
+		// Skip whatever the handler left of the previous streamed body.
+		r.pending.ctx = ctx
+		_, err := io.Copy(io.Discard, &bodyReader{reader: r, request: r.pending})
+		if err != nil {
+			return nil, err
+		}
+		r.pending = nil
+	}
+
+	request := newRequest(r.Limits)
+	request.streamed = r.StreamBody
+	request.ctx = ctx
+	request.readStart = time.Now()
+	for !request.done() && !(r.StreamBody && request.headersDone()) {
+		err := r.advance(request)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if r.StreamBody {
+		request.BodyReader = &bodyReader{reader: r, request: request}
+		r.pending = request
+	} else {
+		request.setBufferedBody()
+	}
+	return request, nil
+}
+
+// advance feeds the buffered bytes to the parser and, when they are not
+// enough for it to make progress, reads more from the connection.
+func (r *Reader) advance(request *Request) error {
+	state := request.state
+	readN, err := request.parse(r.buf[:r.bufIdx])
+	if err != nil {
+		return newParseError(request, request.parsedBytes, err)
+	}
+	copy(r.buf, r.buf[readN:r.bufIdx])
+	r.bufIdx -= readN
+
+	if readN > 0 || request.state != state {
+		return nil
+	}
+
+	if r.bufIdx == len(r.buf) {
+		// The parser needs more than a full buffer to make progress, so grow
+		// it; the limits stop this from happening indefinitely.
+		buf := make([]byte, len(r.buf)*2)
+		copy(buf, r.buf)
+		r.buf = buf
+		if r.pooled != nil {
+			bufferPool.Put(r.pooled)
+			r.pooled = nil
+		}
+	}
+
+	n, err := r.read(request, r.buf[r.bufIdx:])
+	r.bufIdx += n
+	if err != nil && n == 0 {
+		if errors.Is(err, io.EOF) {
+			if request.state == requestStateInit && r.bufIdx == 0 {
+				return io.EOF
+			}
+			return newParseError(request, request.parsedBytes+int64(r.bufIdx), io.ErrUnexpectedEOF)
+		}
+		if isTimeout(err) {
+			return newParseError(request, request.parsedBytes+int64(r.bufIdx), err)
+		}
+		return err
+	}
+	return nil
+}
+
+// bodyReader decodes a streamed body. Decoded bytes are collected in the
+// request's decoded buffer by the parser and handed out from there, so the
+// body framing is handled by the same state machine as a buffered request.
+type bodyReader struct {
+	reader  *Reader
+	request *Request
+	closed  bool
+	err     error
+}
+
+func (b *bodyReader) Read(p []byte) (int, error) {
+	if b.closed {
+		return 0, ErrBodyClosed
+	}
+
+	for len(b.request.decoded) == 0 {
+		if b.err != nil {
+			return 0, b.err
+		}
+		if b.request.done() {
+			return 0, io.EOF
+		}
+		b.err = b.reader.advance(b.request)
+	}
+
+	n := copy(p, b.request.decoded)
+	if n == len(b.request.decoded) {
+		b.request.decoded = b.request.decoded[:0]
+	} else {
+		b.request.decoded = b.request.decoded[n:]
+	}
+	return n, nil
+}
+
+// Close stops the body from being read further. Any unread part is skipped by
+// the next call to ReadRequest.
+func (b *bodyReader) Close() error {
+	b.closed = true
+	return nil
+}
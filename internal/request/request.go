@@ -1,19 +1,25 @@
 // Package request parses HTTP/1.1 requests from a stream.
 //
 // It reads and validates the request line, then incrementally parses header
-// fields until the request is complete. The parser is stateful and supports
-// partial reads from the provided io.Reader.
+// fields and the body until the request is complete. Bodies are framed either
+// by Content-Length or by the chunked transfer coding, and can either be
+// buffered in full or streamed to the caller as it arrives. The parser is
+// stateful and supports partial reads from the provided io.Reader.
 package request
 
 import (
 	"bytes"
+	"context"
 	"errors"
-	"fmt"
 	"io"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
 
+	"github.com/Dawid-Klos/httpfromtcp/internal/chunked"
+	"github.com/Dawid-Klos/httpfromtcp/internal/cookie"
+	"github.com/Dawid-Klos/httpfromtcp/internal/framing"
 	"github.com/Dawid-Klos/httpfromtcp/internal/headers"
 )
 
@@ -21,13 +27,98 @@ type RequestLine struct {
 	HTTPVersion string
 	Target      string
 	Method      string
+	// Version is HTTPVersion parsed into its major and minor numbers. A
+	// request built by hand may set either field; when Version is zero,
+	// HTTPVersion is parsed instead, and a request setting neither is sent as
+	// HTTP/1.1.
+	Version Version
+	// TargetForm is the form of Target. Proxies see absolute-form and
+	// authority-form targets, and server-wide OPTIONS requests use "*".
+	TargetForm TargetForm
+	// URL is Target split into its parts.
+	URL URL
+}
+
+// Version is an HTTP protocol version such as 1.1.
+type Version struct {
+	Major int
+	Minor int
+}
+
+var HTTP10 = Version{Major: 1, Minor: 0}
+var HTTP11 = Version{Major: 1, Minor: 1}
+
+func (v Version) String() string {
+	return strconv.Itoa(v.Major) + "." + strconv.Itoa(v.Minor)
 }
 
 type Request struct {
 	RequestLine RequestLine
 	Headers     headers.Headers
-	Body        []byte
-	state       parserState
+	// Body holds the decoded body. It stays empty when the request was read
+	// by a Reader with StreamBody set.
+	Body []byte
+	// BodyReader reads the decoded body. For a buffered request it reads from
+	// Body; for a streamed one it decodes the body from the connection as it
+	// is read.
+	BodyReader io.ReadCloser
+	// Trailers holds the trailer fields of a chunked body. For a streamed
+	// body it is filled in once BodyReader has been read to the end.
+	Trailers headers.Headers
+	state    parserState
+
+	limits        Limits
+	ctx           context.Context
+	readStart     time.Time
+	bodyStart     time.Time
+	bodyRead      int64
+	parsedBytes   int64
+	headerBytes   framing.SectionSize
+	bodyBytes     int64
+	contentLength int64
+	chunked       *chunked.Decoder
+	// declaredTrailers lists the field names declared by Trailer, or is nil
+	// when the request has no Trailer field.
+	declaredTrailers []string
+	buffered         bufferedBody
+	// storage backs Headers and the request line, so that parsing a typical
+	// request allocates nothing beyond the Request itself. It is not pooled:
+	// the parsed strings point into it and may outlive any use of the Request
+	// the parser could see.
+	storage headers.Storage
+	// streamed is set for a request whose body is read through a streaming
+	// BodyReader. Decoded body bytes then collect in decoded until read,
+	// rather than in Body.
+	streamed bool
+	decoded  []byte
+}
+
+// bufferedBody is the BodyReader of a buffered request. It lives inside the
+// Request so that setting it up does not allocate.
+type bufferedBody struct {
+	bytes.Reader
+}
+
+func (b *bufferedBody) Close() error {
+	return nil
+}
+
+// Limits bounds how much a client may send. A zero field means no limit.
+type Limits struct {
+	// MaxRequestLineLength is the longest request line accepted, excluding
+	// the terminating CRLF.
+	MaxRequestLineLength int
+	// MaxHeaderBytes bounds the header section, and separately the trailer
+	// section of a chunked body, including line terminators.
+	MaxHeaderBytes int
+	// MaxBodyBytes bounds the decoded body.
+	MaxBodyBytes int64
+}
+
+var DefaultLimits = Limits{
+	MaxRequestLineLength: 8 << 10,
+	MaxHeaderBytes:       64 << 10,
+	MaxBodyBytes:         10 << 20,
 }
 
 type parserState string
@@ -36,6 +127,7 @@ const (
 	requestStateInit           parserState = "init"
 	requestStateParsingHeaders parserState = "parsing headers"
 	requestStateParsingBody    parserState = "parsingBody"
+	requestStateParsingChunked parserState = "parsing chunked body"
 	requestStateDone           parserState = "done"
 )
 
@@ -50,102 +142,148 @@ var ErrMalformedMethod = errors.New("malformed method in request line")
 var ErrMalformedVersion = errors.New("malformed version in request line")
 var ErrMalformedTarget = errors.New("malformed target in request line")
 
-var ErrMalformedContentLength = errors.New("malformed Content-Length")
-var ErrBodyOverflow = errors.New("body exceeds Content-Length")
+var ErrMalformedContentLength = framing.ErrMalformedContentLength
+var ErrSignedContentLength = framing.ErrSignedContentLength
+var ErrConflictingContentLength = framing.ErrConflictingContentLength
+var ErrContentLengthWithTransferEncoding = errors.New("both Transfer-Encoding and Content-Length")
+var ErrTransferEncodingInHTTP10 = errors.New("Transfer-Encoding in an HTTP/1.0 request")
+var ErrMalformedTransferEncoding = errors.New("chunked is not the only final transfer coding")
+var ErrUnsupportedTransferEncoding = errors.New("unsupported transfer coding")
+var ErrWhitespaceBeforeColon = headers.ErrWhitespaceBeforeColon
 
-func RequestFromReader(reader io.Reader) (*Request, error) {
-	request := &Request{
-		state:   requestStateInit,
-		Headers: headers.NewHeaders(),
-	}
+var ErrMalformedChunkSize = chunked.ErrMalformedChunkSize
+var ErrMalformedChunkExtension = chunked.ErrMalformedChunkExtension
+var ErrMalformedChunk = chunked.ErrMalformedChunk
+var ErrChunkLineTooLong = chunked.ErrChunkLineTooLong
 
-	buf := make([]byte, 512)
-	bufIdx := 0
-	for !request.done() {
-		n, err := reader.Read(buf[bufIdx:])
-		if err != nil {
-			if errors.Is(err, io.EOF) {
-				if request.state != requestStateDone {
-					return nil, fmt.Errorf("incomplete request, in state: %s, read n bytes on EOF: %d", request.state, bufIdx)
-				}
-			}
-			return nil, err
-		}
+var ErrRequestLineTooLong = errors.New("request line too long")
+var ErrHeadersTooLarge = errors.New("header section too large")
+var ErrBodyTooLarge = errors.New("body too large")
 
-		bufIdx += n
-		readN, err := request.parse(buf[:bufIdx])
-		if err != nil {
-			return nil, err
-		}
+// RequestFromReader parses a single request from reader. Any bytes following
+// the request are discarded; use a Reader to parse successive requests from
+// the same connection.
+func RequestFromReader(reader io.Reader) (*Request, error) {
+	r := NewReader(reader)
+	defer r.Release()
+	return r.ReadRequest()
+}
 
-		copy(buf, buf[readN:bufIdx])
-		bufIdx -= readN
-	}
+// RequestFromReaderContext is RequestFromReader bounded by ctx. Use a Reader
+// to also set header and body timeouts.
+func RequestFromReaderContext(ctx context.Context, reader io.Reader) (*Request, error) {
+	r := NewReader(reader)
+	defer r.Release()
+	return r.ReadRequestContext(ctx)
+}
 
-	return request, nil
+func newRequest(limits Limits) *Request {
+	r := &Request{
+		state:  requestStateInit,
+		limits: limits,
+	}
+	r.Headers = r.storage.Headers()
+	return r
 }
 
-func parseRequestLine(data []byte) (*RequestLine, int, error) {
+// parseRequestLine parses the request line at the start of data. Its text is
+// copied with intern, and every part of the result is a view into the copy.
+func parseRequestLine(data []byte, intern func([]byte) string) (RequestLine, int, error) {
 	idx := bytes.Index(data, CRLF)
 	if idx == -1 {
-		return nil, 0, nil
+		return RequestLine{}, 0, nil
 	}
 
-	requestLineText := string(data[:idx])
+	requestLineText := intern(data[:idx])
 	read := idx + len(CRLF)
 	requestLine, err := requestLineFromString(requestLineText)
 	if err != nil {
-		return nil, idx + 2, err
+		return RequestLine{}, idx + 2, err
 	}
 
 	return requestLine, read, nil
 }
 
-func requestLineFromString(str string) (*RequestLine, error) {
-	parts := strings.Split(str, " ")
-	if len(parts) != 3 {
-		return nil, ErrMalformedRequestLine
+func requestLineFromString(str string) (RequestLine, error) {
+	method, rest, ok := strings.Cut(str, " ")
+	target, versionText, ok2 := strings.Cut(rest, " ")
+	if !ok || !ok2 || strings.Contains(versionText, " ") {
+		return RequestLine{}, ErrMalformedRequestLine
 	}
 
-	method := parts[0]
-	if strings.ToUpper(method) != method {
-		return nil, ErrMalformedMethod
+	if method == "" || strings.ToUpper(method) != method {
+		return RequestLine{}, ErrMalformedMethod
 	}
 
 	for _, letter := range method {
 		if !unicode.IsLetter(letter) {
-			return nil, ErrMalformedMethod
+			return RequestLine{}, ErrMalformedMethod
 		}
 	}
 
-	versionParts := strings.Split(parts[2], "/")
-	if len(versionParts) != 2 {
-		return nil, ErrMalformedRequestLine
+	httpPart, version, ok := strings.Cut(versionText, "/")
+	if !ok || strings.Contains(version, "/") {
+		return RequestLine{}, ErrMalformedRequestLine
 	}
-	httpPart := versionParts[0]
 	if httpPart != "HTTP" {
-		return nil, ErrUnsuppportedHTTPVersion
+		return RequestLine{}, ErrMalformedVersion
 	}
-	version := versionParts[1]
-	if version != "1.1" {
-		return nil, ErrUnsuppportedHTTPVersion
+	parsedVersion, err := parseVersion(version)
+	if err != nil {
+		return RequestLine{}, err
 	}
 
-	target := parts[1]
-	if !strings.Contains(target[:1], "/") {
-		return nil, ErrMalformedTarget
-	}
-	if strings.Contains(target, " ") {
-		return nil, ErrMalformedTarget
+	targetForm, err := parseTarget(method, target)
+	if err != nil {
+		return RequestLine{}, err
 	}
 
-	return &RequestLine{
+	return RequestLine{
 		HTTPVersion: version,
 		Target:      target,
 		Method:      method,
+		Version:     parsedVersion,
+		TargetForm:  targetForm,
+		URL:         parseURL(targetForm, target),
 	}, nil
 }
 
+// parseVersion parses the DIGIT "." DIGIT part of an HTTP-version. Any
+// HTTP/1.x version is accepted: a minor version above 1 is handled as
+// HTTP/1.1, as RFC 9110 section 2.5 recommends. Other major versions are
+// well-formed but unsupported, which a server answers with 505.
+func parseVersion(version string) (Version, error) {
+	if len(version) != 3 || version[1] != '.' || !isDigit(version[0]) || !isDigit(version[2]) {
+		return Version{}, ErrMalformedVersion
+	}
+
+	v := Version{
+		Major: int(version[0] - '0'),
+		Minor: int(version[2] - '0'),
+	}
+	if v.Major != 1 {
+		return Version{}, ErrUnsuppportedHTTPVersion
+	}
+	return v, nil
+}
+
+// version returns the protocol version of the request line. A request line
+// built by hand may set HTTPVersion alone, which is parsed then, and one that
+// sets neither is HTTP/1.1.
+func (rl *RequestLine) version() (Version, error) {
+	if rl.Version != (Version{}) {
+		return rl.Version, nil
+	}
+	if rl.HTTPVersion == "" {
+		return HTTP11, nil
+	}
+	return parseVersion(rl.HTTPVersion)
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
 func (r *Request) parse(data []byte) (int, error) {
 	totalParsedBytes := 0
 	for r.state != requestStateDone {
@@ -154,6 +292,7 @@ func (r *Request) parse(data []byte) (int, error) {
 			return 0, err
 		}
 		totalParsedBytes += n
+		r.parsedBytes += int64(n)
 		if n == 0 {
 			break
 		}
@@ -165,14 +304,21 @@ func (r *Request) parse(data []byte) (int, error) {
 func (r *Request) parseSingle(data []byte) (int, error) {
 	switch r.state {
 	case requestStateInit:
-		rl, n, err := parseRequestLine(data)
+		rl, n, err := parseRequestLine(data, r.storage.Intern)
+		lineLength := n - len(CRLF)
+		if n == 0 {
+			lineLength = len(data)
+		}
+		if framing.Exceeds(lineLength, r.limits.MaxRequestLineLength) {
+			return 0, ErrRequestLineTooLong
+		}
 		if err != nil {
 			return 0, err
 		}
 		if n == 0 {
 			return 0, nil
 		}
-		r.RequestLine = *rl
+		r.RequestLine = rl
 		r.state = requestStateParsingHeaders
 		return n, nil
 	case requestStateParsingHeaders:
@@ -180,40 +326,53 @@ func (r *Request) parseSingle(data []byte) (int, error) {
 		if err != nil {
 			return 0, err
 		}
+		err = r.countHeaderBytes(n, data)
+		if err != nil {
+			return 0, err
+		}
 		if done {
-			r.state = requestStateParsingBody
+			err = r.startBody()
+			if err != nil {
+				return 0, err
+			}
 		}
 		return n, nil
 	case requestStateParsingBody:
-		lengthStr, err := r.Headers.Get("Content-Length")
-		if err != nil {
-			if errors.Is(err, headers.ErrFieldNameNotFound) {
-				r.state = requestStateDone
-				return 0, nil
-			}
-			return 0, err
+		n := int(min(int64(len(data)), r.contentLength-r.bodyBytes))
+		r.appendBody(data[:n])
+		if r.bodyBytes == r.contentLength {
+			r.state = requestStateDone
 		}
-		contentLen, err := strconv.Atoi(lengthStr)
+
+		return n, nil
+	case requestStateParsingChunked:
+		inTrailers := r.chunked.InTrailers()
+		n, chunk, err := r.chunked.Decode(data)
 		if err != nil {
-			return 0, ErrMalformedContentLength
+			return 0, err
 		}
-
-		if contentLen == 0 {
-			r.state = requestStateDone
+		if inTrailers {
+			err = r.countHeaderBytes(n, data)
+			if err != nil {
+				return 0, err
+			}
 		}
-		if len(data) < contentLen {
-			return 0, nil
+		if framing.Exceeds(r.bodyBytes+int64(len(chunk)), r.limits.MaxBodyBytes) {
+			return 0, ErrBodyTooLarge
 		}
-		if len(data) > contentLen {
-			return 0, ErrBodyOverflow
+		r.appendBody(chunk)
+		if r.chunked.InTrailers() && !inTrailers {
+			r.headerBytes.Reset()
 		}
-
-		r.Body = append(r.Body, data...)
-		if len(r.Body) == contentLen {
+		if r.chunked.Done() {
+			err = checkTrailers(r.declaredTrailers, &r.chunked.Trailers)
+			if err != nil {
+				return 0, err
+			}
+			r.Trailers = r.chunked.Trailers
 			r.state = requestStateDone
 		}
-
-		return len(data), nil
+		return n, nil
 	case requestStateDone:
 		return 0, ErrReadingDataInDoneState
 	default:
@@ -221,6 +380,166 @@ func (r *Request) parseSingle(data []byte) (int, error) {
 	}
 }
 
+// startBody picks the body framing once the header section is complete,
+// following RFC 9112 section 6.3. Framing that a front-end proxy and this
+// parser could read differently is rejected rather than resolved, as that
+// disagreement is what request smuggling exploits. A request with neither
+// Transfer-Encoding nor Content-Length has no body.
+func (r *Request) startBody() error {
+	te := r.Headers.Values("Transfer-Encoding")
+	cl := r.Headers.Values("Content-Length")
+	if len(te) > 0 {
+		if r.RequestLine.Version == HTTP10 {
+			// HTTP/1.0 predates transfer codings, so RFC 9112 section 6.1
+			// requires treating the framing as faulty.
+			return ErrTransferEncodingInHTTP10
+		}
+		if len(cl) > 0 {
+			return ErrContentLengthWithTransferEncoding
+		}
+		err := checkTransferCodings(te)
+		if err != nil {
+			return err
+		}
+		r.declaredTrailers, err = parseTrailerDeclaration(r.Headers.Values("Trailer"))
+		if err != nil {
+			return err
+		}
+		r.chunked = chunked.NewDecoder()
+		r.state = requestStateParsingChunked
+		return nil
+	}
+
+	if len(cl) == 0 {
+		r.state = requestStateDone
+		return nil
+	}
+	contentLen, err := framing.ParseContentLength(cl)
+	if err != nil {
+		return err
+	}
+	if framing.Exceeds(contentLen, r.limits.MaxBodyBytes) {
+		return ErrBodyTooLarge
+	}
+
+	r.contentLength = contentLen
+	r.state = requestStateParsingBody
+	if contentLen == 0 {
+		r.state = requestStateDone
+	}
+	return nil
+}
+
+// checkTransferCodings accepts exactly one coding: chunked. Other codings are
+// not decoded by this parser, and chunked must be applied once, last.
+func checkTransferCodings(values []string) error {
+	var codings []string
+	for _, value := range values {
+		for _, coding := range strings.Split(value, ",") {
+			coding = strings.Trim(coding, headers.OWS)
+			if coding != "" {
+				codings = append(codings, coding)
+			}
+		}
+	}
+
+	if len(codings) == 0 {
+		return ErrMalformedTransferEncoding
+	}
+	for i, coding := range codings {
+		if !strings.EqualFold(coding, "chunked") {
+			if i == len(codings)-1 {
+				return ErrMalformedTransferEncoding
+			}
+			return ErrUnsupportedTransferEncoding
+		}
+		if i != len(codings)-1 {
+			return ErrMalformedTransferEncoding
+		}
+	}
+	return nil
+}
+
+func (r *Request) setBufferedBody() {
+	r.buffered.Reset(r.Body)
+	r.BodyReader = &r.buffered
+}
+
+func (r *Request) appendBody(data []byte) {
+	if r.streamed {
+		r.decoded = append(r.decoded, data...)
+	} else {
+		r.Body = append(r.Body, data...)
+	}
+	r.bodyBytes += int64(len(data))
+}
+
+// countHeaderBytes adds a field line to the size of the header or trailer
+// section, as framing.SectionSize.Add does.
+func (r *Request) countHeaderBytes(n int, data []byte) error {
+	if !r.headerBytes.Add(n, data, r.limits.MaxHeaderBytes) {
+		return ErrHeadersTooLarge
+	}
+	return nil
+}
+
+// KeepAlive reports whether the connection may be reused for another request
+// once this one has been answered. HTTP/1.1 connections are persistent unless
+// the client sends "Connection: close"; HTTP/1.0 connections are closed unless
+// the client asks for "Connection: keep-alive".
+func (r *Request) KeepAlive() bool {
+	connection, err := r.Headers.Get("Connection")
+	if err != nil {
+		return !r.isHTTP10()
+	}
+	if framing.HasToken(connection, "close") {
+		return false
+	}
+	if r.isHTTP10() {
+		return framing.HasToken(connection, "keep-alive")
+	}
+	return true
+}
+
+// ExpectsContinue reports whether the client sent "Expect: 100-continue" and
+// is holding back the body until it receives a 100 (Continue) response. Only
+// a request read by a Reader with StreamBody set can report true, since a
+// buffered request has its body read already. The expectation is ignored for
+// HTTP/1.0, as RFC 9110 section 10.1.1 requires.
+func (r *Request) ExpectsContinue() bool {
+	if r.done() || r.isHTTP10() {
+		return false
+	}
+	expect, err := r.Headers.Get("Expect")
+	return err == nil && strings.EqualFold(strings.Trim(expect, headers.OWS), "100-continue")
+}
+
+// Cookies parses the cookies sent in the Cookie field. A client sends a single
+// Cookie field, but separate ones are accepted too and read in order. Pairs
+// that are malformed are skipped and reported with cookie.ErrMalformedCookie.
+func (r *Request) Cookies() ([]cookie.Cookie, error) {
+	var cookies []cookie.Cookie
+	var err error
+	for _, line := range r.Headers.Values("Cookie") {
+		parsed, parseErr := cookie.Parse(line)
+		if parseErr != nil {
+			err = parseErr
+		}
+		cookies = append(cookies, parsed...)
+	}
+	return cookies, err
+}
+
+func (r *Request) isHTTP10() bool {
+	version, err := r.RequestLine.version()
+	return err == nil && version == HTTP10
+}
+
 func (r *Request) done() bool {
 	return r.state == requestStateDone
 }
+
+// headersDone reports whether the parser has moved past the header section.
+func (r *Request) headersDone() bool {
+	return r.state != requestStateInit && r.state != requestStateParsingHeaders
+}
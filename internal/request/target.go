@@ -0,0 +1,189 @@
+package request
+
+import (
+	"errors"
+	"strings"
+)
+
+// TargetForm is the form of a request-target as defined in RFC 9112 section
+// 3.2.
+type TargetForm string
+
+const (
+	// OriginForm is an absolute path with an optional query, such as
+	// "/where?q=now". It is used for requests sent directly to an origin
+	// server.
+	OriginForm TargetForm = "origin-form"
+	// AbsoluteForm is a complete URI, such as "http://example.com/where". It
+	// is used for requests sent to a proxy.
+	AbsoluteForm TargetForm = "absolute-form"
+	// AuthorityForm is a host and port, such as "example.com:443". It is only
+	// used with CONNECT.
+	AuthorityForm TargetForm = "authority-form"
+	// AsteriskForm is the single "*" used by a server-wide OPTIONS request.
+	AsteriskForm TargetForm = "asterisk-form"
+)
+
+var ErrTargetFormNotAllowed = errors.New("request-target form not allowed for method")
+
+// parseTarget determines the form of a request-target and checks that it is
+// well formed and allowed for method.
+func parseTarget(method string, target string) (TargetForm, error) {
+	if target == "" {
+		return "", ErrMalformedTarget
+	}
+	if !validTargetBytes(target) {
+		return "", ErrMalformedTarget
+	}
+	// A fragment is only meaningful to the client and must not be sent.
+	if strings.IndexByte(target, '#') != -1 {
+		return "", ErrTargetFragment
+	}
+
+	var form TargetForm
+	var valid bool
+	switch {
+	case target == "*":
+		form, valid = AsteriskForm, true
+	case target[0] == '/':
+		form, valid = OriginForm, validPathQuery(target)
+	case strings.Contains(target, "://"):
+		form, valid = AbsoluteForm, validAbsoluteForm(target)
+	default:
+		form, valid = AuthorityForm, validAuthorityForm(target)
+	}
+	if !valid {
+		return "", ErrMalformedTarget
+	}
+
+	switch {
+	case method == "CONNECT" && form != AuthorityForm:
+		return "", ErrTargetFormNotAllowed
+	case form == AuthorityForm && method != "CONNECT":
+		return "", ErrTargetFormNotAllowed
+	case form == AsteriskForm && method != "OPTIONS":
+		return "", ErrTargetFormNotAllowed
+	}
+	return form, nil
+}
+
+// validTargetBytes reports whether target is free of whitespace, control
+// characters and raw non-ASCII bytes, which no form allows.
+func validTargetBytes(target string) bool {
+	for i := 0; i < len(target); i++ {
+		if target[i] <= ' ' || target[i] >= 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// validAuthorityForm checks a CONNECT target: a host and a port, without
+// userinfo.
+func validAuthorityForm(target string) bool {
+	host, port, ok := splitHostPort(target)
+	return ok && port != "" && validHost(host)
+}
+
+// validAbsoluteForm checks for a scheme followed by "://", an authority
+// without userinfo and an optional path and query.
+func validAbsoluteForm(target string) bool {
+	scheme, rest, ok := strings.Cut(target, "://")
+	if !ok || !validScheme(scheme) {
+		return false
+	}
+
+	authority, pathQuery := rest, ""
+	if i := strings.IndexAny(rest, "/?"); i != -1 {
+		authority, pathQuery = rest[:i], rest[i:]
+	}
+	host, _, ok := splitHostPort(authority)
+	return ok && host != "" && validHost(host) && validPathQuery(pathQuery)
+}
+
+// validScheme checks scheme = ALPHA *( ALPHA / DIGIT / "+" / "-" / "." ).
+func validScheme(scheme string) bool {
+	if scheme == "" || !isAlpha(scheme[0]) {
+		return false
+	}
+	for i := 1; i < len(scheme); i++ {
+		c := scheme[i]
+		if !isAlpha(c) && !isDigit(c) && c != '+' && c != '-' && c != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+// splitHostPort splits an authority into its host and optional port. An
+// IP-literal host keeps its brackets.
+func splitHostPort(authority string) (host string, port string, ok bool) {
+	host = authority
+	if strings.HasPrefix(authority, "[") {
+		end := strings.IndexByte(authority, ']')
+		if end == -1 {
+			return "", "", false
+		}
+		host = authority[:end+1]
+		rest := authority[end+1:]
+		if rest == "" {
+			return host, "", true
+		}
+		if rest[0] != ':' {
+			return "", "", false
+		}
+		port = rest[1:]
+	} else if i := strings.LastIndexByte(authority, ':'); i != -1 {
+		host, port = authority[:i], authority[i+1:]
+	}
+
+	for i := 0; i < len(port); i++ {
+		if !isDigit(port[i]) {
+			return "", "", false
+		}
+	}
+	return host, port, true
+}
+
+// validHost checks an IP-literal in brackets, or a reg-name, which also covers
+// IPv4 addresses. A "@" from userinfo is rejected, as RFC 9110 section 4.2.4
+// requires for http URIs.
+func validHost(host string) bool {
+	if host == "" {
+		return false
+	}
+	if host[0] == '[' {
+		for i := 1; i < len(host)-1; i++ {
+			c := host[i]
+			if !isHexDigit(c) && c != ':' && c != '.' {
+				return false
+			}
+		}
+		return host[len(host)-1] == ']' && len(host) > 2
+	}
+	for i := 0; i < len(host); i++ {
+		c := host[i]
+		if !isUnreserved(c) && !isSubDelim(c) && c != '%' {
+			return false
+		}
+	}
+	return true
+}
+
+func isAlpha(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+// isUnreserved reports whether c is an unreserved character of RFC 3986.
+func isUnreserved(c byte) bool {
+	return isAlpha(c) || isDigit(c) || c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+// isSubDelim reports whether c is one of the sub-delims of RFC 3986.
+func isSubDelim(c byte) bool {
+	return strings.IndexByte("!$&'()*+,;=", c) != -1
+}
@@ -0,0 +1,119 @@
+package request
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+var ErrHeaderTimeout = errors.New("timeout reading request headers")
+var ErrBodyTimeout = errors.New("timeout reading request body")
+var ErrBodyTooSlow = errors.New("request body sent below the minimum rate")
+
+// deadlineReader is implemented by net.Conn.
+type deadlineReader interface {
+	io.Reader
+	SetReadDeadline(t time.Time) error
+}
+
+// aLongTimeAgo is a deadline in the past, which makes a blocked read return
+// at once.
+var aLongTimeAgo = time.Unix(1, 0)
+
+// read reads from the connection once, bounded by the request context and
+// the Reader's timeouts. A timeout is reported as ErrHeaderTimeout,
+// ErrBodyTimeout or ErrBodyTooSlow, and a done context as its error.
+func (r *Reader) read(request *Request, p []byte) (int, error) {
+	ctx := request.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	if request.headersDone() && request.bodyStart.IsZero() {
+		request.bodyStart = time.Now()
+	}
+
+	deadline, cause := r.deadline(ctx, request)
+	if !deadline.IsZero() && !time.Now().Before(deadline) {
+		return 0, cause
+	}
+
+	conn, ok := r.reader.(deadlineReader)
+	if !ok || (deadline.IsZero() && ctx.Done() == nil && !r.deadlineSet) {
+		n, err := r.reader.Read(p)
+		r.countBody(request, n)
+		return n, err
+	}
+
+	conn.SetReadDeadline(deadline)
+	r.deadlineSet = !deadline.IsZero()
+	if ctx.Done() != nil {
+		stop := context.AfterFunc(ctx, func() {
+			conn.SetReadDeadline(aLongTimeAgo)
+		})
+		defer func() {
+			if !stop() {
+				// The context ended and moved the deadline, which has to be
+				// reset before the next read.
+				r.deadlineSet = true
+			}
+		}()
+	}
+
+	n, err := conn.Read(p)
+	r.countBody(request, n)
+	if err != nil && ctx.Err() != nil {
+		return n, ctx.Err()
+	}
+	if errors.Is(err, os.ErrDeadlineExceeded) && cause != nil {
+		return n, cause
+	}
+	return n, err
+}
+
+// deadline returns when the current read has to finish, and the error to
+// report if it does not. The zero time means there is no deadline.
+func (r *Reader) deadline(ctx context.Context, request *Request) (time.Time, error) {
+	var deadline time.Time
+	var cause error
+	earliest := func(t time.Time, err error) {
+		if deadline.IsZero() || t.Before(deadline) {
+			deadline, cause = t, err
+		}
+	}
+
+	if !request.headersDone() {
+		if r.HeaderTimeout > 0 {
+			earliest(request.readStart.Add(r.HeaderTimeout), ErrHeaderTimeout)
+		}
+	} else {
+		if r.BodyTimeout > 0 {
+			earliest(request.bodyStart.Add(r.BodyTimeout), ErrBodyTimeout)
+		}
+		if r.MinBodyRate > 0 {
+			allowed := time.Duration(float64(request.bodyRead+r.MinBodyRate) / float64(r.MinBodyRate) * float64(time.Second))
+			earliest(request.bodyStart.Add(allowed), ErrBodyTooSlow)
+		}
+	}
+
+	if ctxDeadline, ok := ctx.Deadline(); ok {
+		earliest(ctxDeadline, context.DeadlineExceeded)
+	}
+	return deadline, cause
+}
+
+// countBody adds n bytes read from the connection to the body transfer rate
+// once the header section is complete.
+func (r *Reader) countBody(request *Request, n int) {
+	if request.headersDone() {
+		request.bodyRead += int64(n)
+	}
+}
+
+func isTimeout(err error) bool {
+	return errors.Is(err, ErrHeaderTimeout) || errors.Is(err, ErrBodyTimeout) || errors.Is(err, ErrBodyTooSlow)
+}
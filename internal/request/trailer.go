@@ -0,0 +1,85 @@
+package request
+
+import (
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/Dawid-Klos/httpfromtcp/internal/headers"
+)
+
+var ErrMalformedTrailer = errors.New("malformed Trailer field")
+var ErrForbiddenTrailer = errors.New("field not allowed in trailer section")
+var ErrUndeclaredTrailer = errors.New("trailer field not declared in Trailer")
+
+// forbiddenTrailers lists the fields RFC 9110 section 6.5.1 rules out of a
+// trailer section: framing, routing, request modifiers, authentication and
+// fields describing the content, all of which have to be known before the
+// body is processed. Names are lower case.
+var forbiddenTrailers = map[string]bool{
+	"authorization":       true,
+	"cache-control":       true,
+	"connection":          true,
+	"content-encoding":    true,
+	"content-length":      true,
+	"content-range":       true,
+	"content-type":        true,
+	"expect":              true,
+	"host":                true,
+	"keep-alive":          true,
+	"max-forwards":        true,
+	"pragma":              true,
+	"proxy-authorization": true,
+	"proxy-connection":    true,
+	"range":               true,
+	"te":                  true,
+	"trailer":             true,
+	"transfer-encoding":   true,
+}
+
+// parseTrailerDeclaration returns the lower-cased field names listed in the
+// Trailer fields of the header section. None of them may be a forbidden
+// trailer field. Without a Trailer field the result is nil, while a Trailer
+// field declaring nothing gives an empty list.
+func parseTrailerDeclaration(values []string) ([]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	names := []string{}
+	for _, value := range values {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.Trim(name, headers.OWS)
+			if name == "" {
+				continue
+			}
+			for i := 0; i < len(name); i++ {
+				if !headers.IsTokenChar(name[i]) {
+					return nil, ErrMalformedTrailer
+				}
+			}
+			name = strings.ToLower(name)
+			if forbiddenTrailers[name] {
+				return nil, ErrForbiddenTrailer
+			}
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// checkTrailers validates the received trailer section. Forbidden fields are
+// always rejected. When the header section declared its trailers, every
+// trailer field has to be one of them; without a declaration any other field
+// is accepted, as senders are only encouraged to declare them.
+func checkTrailers(declared []string, trailers *headers.Headers) error {
+	for name := range trailers.All() {
+		name = strings.ToLower(name)
+		if forbiddenTrailers[name] {
+			return ErrForbiddenTrailer
+		}
+		if declared != nil && !slices.Contains(declared, name) {
+			return ErrUndeclaredTrailer
+		}
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package request
+
+import (
+	"errors"
+	"strings"
+)
+
+// URL is the request-target broken into its parts. Which parts are set
+// depends on the TargetForm: an origin-form target has a path and query, an
+// absolute-form target also has a scheme and host, an authority-form target
+// only has a host and an asterisk-form target has none.
+type URL struct {
+	Scheme string
+	// Host is the authority: a host and an optional port.
+	Host string
+	// Path is the percent-decoded path. Decoding is lossy, as "%2F" becomes
+	// "/"; use RawPath when the difference matters.
+	Path string
+	// RawPath is the path as sent, still percent-encoded.
+	RawPath string
+	// RawQuery is the query as sent, without the leading "?".
+	RawQuery string
+}
+
+// Values maps a query or form parameter name to its values in the order they
+// were sent.
+type Values map[string][]string
+
+var ErrTargetFragment = errors.New("fragment in request-target")
+var ErrMalformedQuery = errors.New("malformed query")
+
+// Get returns the first value of the named parameter, or "" if there is none.
+func (v Values) Get(name string) string {
+	if len(v[name]) == 0 {
+		return ""
+	}
+	return v[name][0]
+}
+
+// Has reports whether the named parameter is present, even without a value.
+func (v Values) Has(name string) bool {
+	_, ok := v[name]
+	return ok
+}
+
+// Query parses RawQuery into parameters. Names and values are
+// percent-decoded and "+" is read as a space, as in HTML form submissions.
+// Query parses RawQuery on every call, so handlers that need it repeatedly
+// should keep the result.
+func (u URL) Query() Values {
+	values, _ := ParseQuery(u.RawQuery)
+	return values
+}
+
+// ParseQuery parses a query in the application/x-www-form-urlencoded format.
+// It returns ErrMalformedQuery for an invalid percent-encoding, in which case
+// the parameters that could be decoded are still returned.
+func ParseQuery(query string) (Values, error) {
+	values := Values{}
+	var err error
+	for query != "" {
+		var pair string
+		pair, query, _ = strings.Cut(query, "&")
+		if pair == "" {
+			continue
+		}
+
+		name, value, _ := strings.Cut(pair, "=")
+		name, nameOK := unescape(name, true)
+		value, valueOK := unescape(value, true)
+		if !nameOK || !valueOK {
+			err = ErrMalformedQuery
+			continue
+		}
+		values[name] = append(values[name], value)
+	}
+	return values, err
+}
+
+// parseURL splits a validated request-target of the given form.
+func parseURL(form TargetForm, target string) URL {
+	var u URL
+	switch form {
+	case AsteriskForm:
+		return u
+	case AuthorityForm:
+		u.Host = target
+		return u
+	case AbsoluteForm:
+		u.Scheme, target, _ = strings.Cut(target, "://")
+		i := strings.IndexAny(target, "/?")
+		if i == -1 {
+			i = len(target)
+		}
+		u.Host, target = target[:i], target[i:]
+	}
+
+	u.RawPath, u.RawQuery, _ = strings.Cut(target, "?")
+	if u.RawPath == "" {
+		// An absolute-form target without a path addresses "/".
+		u.RawPath = "/"
+	}
+	u.Path, _ = unescape(u.RawPath, false)
+	return u
+}
+
+// validPathQuery checks an absolute path with an optional query against the
+// characters RFC 3986 allows: pchar and "/" in the path, plus "?" in the
+// query, with every "%" starting a pct-encoded byte.
+func validPathQuery(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case isUnreserved(c) || isSubDelim(c) || c == ':' || c == '@' || c == '/' || c == '?':
+		case c == '%':
+			if i+2 >= len(s) || !isHexDigit(s[i+1]) || !isHexDigit(s[i+2]) {
+				return false
+			}
+			i += 2
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// unescape decodes pct-encoded bytes in s, and "+" as a space when plus is
+// set. It reports false for a "%" that does not start a pct-encoded byte.
+func unescape(s string, plus bool) (string, bool) {
+	if !strings.ContainsAny(s, "%+") {
+		return s, true
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '%':
+			if i+2 >= len(s) || !isHexDigit(s[i+1]) || !isHexDigit(s[i+2]) {
+				return "", false
+			}
+			b.WriteByte(unhex(s[i+1])<<4 | unhex(s[i+2]))
+			i += 2
+		case c == '+' && plus:
+			b.WriteByte(' ')
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), true
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
@@ -0,0 +1,253 @@
+package request
+
+import (
+	"errors"
+	"io"
+	"strconv"
+
+	"github.com/Dawid-Klos/httpfromtcp/internal/chunked"
+	"github.com/Dawid-Klos/httpfromtcp/internal/framing"
+	"github.com/Dawid-Klos/httpfromtcp/internal/headers"
+)
+
+// chunkSize is the largest chunk WriteTo emits when encoding a streamed body.
+const chunkSize = 32 << 10
+
+var ErrContentLengthMismatch = errors.New("body length does not match Content-Length")
+
+// WriteTo serializes the request in wire format: the request line, the header
+// fields and the body. The body is taken from Body, or from BodyReader when
+// Body is empty.
+//
+// The request line and the fields are checked before anything is written: the
+// method must be a token, the target must not contain whitespace or control
+// characters, and header and trailer fields must pass headers.Validate. Any of
+// these could otherwise end a line early and inject a request of its own.
+//
+// The framing declared by the headers is honoured: a chunked Transfer-Encoding
+// makes the body chunked, and a Content-Length must match the body. Framing
+// that a parser would reject is refused before anything is written: a
+// Transfer-Encoding in an HTTP/1.0 request or one that does not end in
+// chunked, a Content-Length next to one, or Content-Length values that are
+// malformed or disagree. When the
+// headers declare neither, a Content-Length is added for bodies of known
+// length and a streamed body is sent chunked. HTTP/1.0 has no chunked coding,
+// so a streamed HTTP/1.0 body is read in full to learn its length.
+func (r *Request) WriteTo(w io.Writer) (int64, error) {
+	if r.RequestLine.Method == "" || r.RequestLine.Target == "" {
+		return 0, ErrMalformedRequestLine
+	}
+	for i := 0; i < len(r.RequestLine.Method); i++ {
+		if !headers.IsTokenChar(r.RequestLine.Method[i]) {
+			return 0, ErrMalformedMethod
+		}
+	}
+	if !validTargetBytes(r.RequestLine.Target) {
+		return 0, ErrMalformedTarget
+	}
+	version, err := r.RequestLine.version()
+	if err != nil {
+		return 0, err
+	}
+	err = r.Headers.Validate()
+	if err != nil {
+		return 0, err
+	}
+	err = r.Trailers.Validate()
+	if err != nil {
+		return 0, err
+	}
+
+	te, teErr := r.Headers.Get("Transfer-Encoding")
+	if teErr == nil && version == HTTP10 {
+		return 0, ErrTransferEncodingInHTTP10
+	}
+	isChunked := teErr == nil && chunked.IsChunked(te)
+	if teErr == nil && !isChunked {
+		// Only chunked delimits the body; adding a Content-Length next to
+		// another coding would make the framing ambiguous.
+		return 0, ErrUnsupportedTransferEncoding
+	}
+	cl := r.Headers.Values("Content-Length")
+	contentLen := int64(-1)
+	if len(cl) > 0 {
+		if isChunked {
+			return 0, ErrContentLengthWithTransferEncoding
+		}
+		n, err := framing.ParseContentLength(cl)
+		if err != nil {
+			return 0, err
+		}
+		contentLen = n
+	}
+
+	body, err := r.outgoingBody()
+	if err != nil {
+		return 0, err
+	}
+	if !body.known && version == HTTP10 {
+		body, err = body.readAll()
+		if err != nil {
+			return 0, err
+		}
+	}
+	if contentLen != -1 && body.known && int64(len(body.data)) != contentLen {
+		return 0, ErrContentLengthMismatch
+	}
+
+	cw := &countingWriter{w: w}
+	cw.writeString(r.RequestLine.Method + " " + r.RequestLine.Target + " HTTP/" + version.String() + "\r\n")
+	for name, value := range r.Headers.All() {
+		cw.writeString(name + ": " + value + "\r\n")
+	}
+
+	switch {
+	case isChunked:
+		cw.writeString("\r\n")
+		r.writeChunked(cw, body)
+	case contentLen != -1:
+		cw.writeString("\r\n")
+		writeFixed(cw, body, contentLen)
+	case body.known && len(body.data) == 0:
+		if expectsBody(r.RequestLine.Method) {
+			cw.writeString("Content-Length: 0\r\n")
+		}
+		cw.writeString("\r\n")
+	case body.known:
+		cw.writeString("Content-Length: " + strconv.Itoa(len(body.data)) + "\r\n\r\n")
+		cw.write(body.data)
+	default:
+		cw.writeString("Transfer-Encoding: chunked\r\n\r\n")
+		r.writeChunked(cw, body)
+	}
+
+	return cw.n, cw.err
+}
+
+// outgoingBody describes the body to write. When only a BodyReader is set,
+// its first chunk is read ahead so that an empty or short stream can be sent
+// with a known length.
+type outgoingBody struct {
+	data  []byte
+	rest  io.Reader
+	known bool
+}
+
+func (r *Request) outgoingBody() (outgoingBody, error) {
+	if len(r.Body) > 0 || r.BodyReader == nil {
+		return outgoingBody{data: r.Body, known: true}, nil
+	}
+
+	buf := make([]byte, chunkSize)
+	n, err := io.ReadFull(r.BodyReader, buf)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return outgoingBody{data: buf[:n], known: true}, nil
+	}
+	if err != nil {
+		return outgoingBody{}, err
+	}
+	return outgoingBody{data: buf[:n], rest: r.BodyReader}, nil
+}
+
+func (b outgoingBody) readAll() (outgoingBody, error) {
+	rest, err := io.ReadAll(b.rest)
+	if err != nil {
+		return outgoingBody{}, err
+	}
+	return outgoingBody{data: append(b.data, rest...), known: true}, nil
+}
+
+func (r *Request) writeChunked(cw *countingWriter, body outgoingBody) {
+	writeChunk(cw, body.data)
+	if body.rest != nil {
+		buf := make([]byte, chunkSize)
+		for cw.err == nil {
+			n, err := body.rest.Read(buf)
+			writeChunk(cw, buf[:n])
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					cw.fail(err)
+				}
+				break
+			}
+		}
+	}
+
+	cw.writeString("0\r\n")
+	for name, value := range r.Trailers.All() {
+		cw.writeString(name + ": " + value + "\r\n")
+	}
+	cw.writeString("\r\n")
+}
+
+func writeChunk(cw *countingWriter, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	cw.writeString(strconv.FormatInt(int64(len(data)), 16) + "\r\n")
+	cw.write(data)
+	cw.writeString("\r\n")
+}
+
+// writeFixed writes a body that must be exactly contentLen bytes long.
+func writeFixed(cw *countingWriter, body outgoingBody, contentLen int64) {
+	if body.rest == nil {
+		if int64(len(body.data)) != contentLen {
+			cw.fail(ErrContentLengthMismatch)
+			return
+		}
+		cw.write(body.data)
+		return
+	}
+
+	if int64(len(body.data)) > contentLen {
+		cw.fail(ErrContentLengthMismatch)
+		return
+	}
+	cw.write(body.data)
+	remaining := contentLen - int64(len(body.data))
+	_, err := io.CopyN(cw, body.rest, remaining)
+	if errors.Is(err, io.EOF) {
+		cw.fail(ErrContentLengthMismatch)
+	} else if err != nil {
+		cw.fail(err)
+	}
+}
+
+// expectsBody reports whether a request with the method usually carries a
+// body, in which case an empty one is announced with Content-Length: 0.
+func expectsBody(method string) bool {
+	return method == "POST" || method == "PUT" || method == "PATCH"
+}
+
+// countingWriter counts the bytes written and remembers the first error, after
+// which every write is skipped.
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	cw.err = err
+	return n, err
+}
+
+func (cw *countingWriter) write(p []byte) {
+	cw.Write(p)
+}
+
+func (cw *countingWriter) writeString(s string) {
+	cw.Write([]byte(s))
+}
+
+func (cw *countingWriter) fail(err error) {
+	if cw.err == nil {
+		cw.err = err
+	}
+}
@@ -0,0 +1,413 @@
+package response
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/Dawid-Klos/httpfromtcp/internal/chunked"
+	"github.com/Dawid-Klos/httpfromtcp/internal/framing"
+	"github.com/Dawid-Klos/httpfromtcp/internal/headers"
+)
+
+type StatusLine struct {
+	HTTPVersion  string
+	StatusCode   StatusCode
+	ReasonPhrase string
+}
+
+type Response struct {
+	StatusLine StatusLine
+	Headers    headers.Headers
+	Body       []byte
+	// Trailers holds the trailer fields of a chunked body.
+	Trailers headers.Headers
+	state    parserState
+
+	method        string
+	limits        Limits
+	headerBytes   framing.SectionSize
+	bodyBytes     int64
+	contentLength int64
+	chunked       *chunked.Decoder
+}
+
+type parserState string
+
+const (
+	responseStateInit            parserState = "init"
+	responseStateParsingHeaders  parserState = "parsing headers"
+	responseStateParsingBody     parserState = "parsing body"
+	responseStateParsingChunked  parserState = "parsing chunked body"
+	responseStateParsingUntilEOF parserState = "parsing body until EOF"
+	responseStateDone            parserState = "done"
+)
+
+// Limits bounds how much a server may send. A zero field means no limit.
+type Limits struct {
+	// MaxStatusLineLength is the longest status line accepted, excluding the
+	// terminating CRLF.
+	MaxStatusLineLength int
+	// MaxHeaderBytes bounds the header section, and separately the trailer
+	// section of a chunked body, including line terminators.
+	MaxHeaderBytes int
+	// MaxBodyBytes bounds the decoded body.
+	MaxBodyBytes int64
+}
+
+var DefaultLimits = Limits{
+	MaxStatusLineLength: 8 << 10,
+	MaxHeaderBytes:      64 << 10,
+	MaxBodyBytes:        10 << 20,
+}
+
+var CRLF = []byte("\r\n")
+
+var ErrReadingDataInDoneState = errors.New("trying to read data in done state")
+var ErrUnknownResponseState = errors.New("unknown response state")
+
+var ErrUnsupportedHTTPVersion = errors.New("unsupported HTTP version")
+var ErrMalformedStatusLine = errors.New("malformed status line")
+var ErrMalformedStatusCode = errors.New("malformed status code in status line")
+var ErrMalformedReasonPhrase = errors.New("malformed reason phrase in status line")
+var ErrMalformedContentLength = framing.ErrMalformedContentLength
+var ErrSignedContentLength = framing.ErrSignedContentLength
+var ErrConflictingContentLength = framing.ErrConflictingContentLength
+
+var ErrStatusLineTooLong = errors.New("status line too long")
+var ErrHeadersTooLarge = errors.New("header section too large")
+var ErrBodyTooLarge = errors.New("body too large")
+
+// ResponseFromReader parses a single response from reader. The method of the
+// request being answered is needed to tell whether the response has a body,
+// since responses to HEAD never do.
+func ResponseFromReader(reader io.Reader, method string) (*Response, error) {
+	return NewReader(reader).ReadResponse(method)
+}
+
+// Reader parses successive responses from a single connection, keeping any
+// bytes read past the end of one response for the next.
+type Reader struct {
+	// Limits bounds each response read. It defaults to DefaultLimits.
+	Limits Limits
+
+	reader io.Reader
+	buf    []byte
+	bufIdx int
+}
+
+func NewReader(reader io.Reader) *Reader {
+	return &Reader{
+		Limits: DefaultLimits,
+		reader: reader,
+		buf:    make([]byte, 512),
+	}
+}
+
+// ReadResponse parses the next response from the connection. It returns
+// io.EOF if the connection is closed cleanly before any byte of the response
+// has been received.
+func (r *Reader) ReadResponse(method string) (*Response, error) {
+	response := &Response{
+		state:   responseStateInit,
+		Headers: headers.NewHeaders(),
+		method:  method,
+		limits:  r.Limits,
+	}
+
+	for !response.done() {
+		state := response.state
+		readN, err := response.parse(r.buf[:r.bufIdx])
+		if err != nil {
+			return nil, err
+		}
+		copy(r.buf, r.buf[readN:r.bufIdx])
+		r.bufIdx -= readN
+
+		if readN > 0 || response.state != state || response.done() {
+			continue
+		}
+
+		if r.bufIdx == len(r.buf) {
+			buf := make([]byte, len(r.buf)*2)
+			copy(buf, r.buf)
+			r.buf = buf
+		}
+
+		n, err := r.reader.Read(r.buf[r.bufIdx:])
+		r.bufIdx += n
+		if err != nil && n == 0 {
+			if !errors.Is(err, io.EOF) {
+				return nil, err
+			}
+			if response.state == responseStateParsingUntilEOF {
+				response.state = responseStateDone
+				break
+			}
+			if response.state == responseStateInit && r.bufIdx == 0 {
+				return nil, io.EOF
+			}
+			return nil, fmt.Errorf("incomplete response, in state: %s, read n bytes on EOF: %d: %w", response.state, r.bufIdx, io.ErrUnexpectedEOF)
+		}
+	}
+
+	return response, nil
+}
+
+// KeepAlive reports whether the connection may carry another response. It is
+// false when the server asks to close the connection, when the body was
+// delimited by the connection closing, and for HTTP/1.0 responses without
+// "Connection: keep-alive".
+func (r *Response) KeepAlive() bool {
+	if r.bodyUntilEOF() {
+		return false
+	}
+	connection, err := r.Headers.Get("Connection")
+	if err != nil {
+		return r.StatusLine.HTTPVersion != "1.0"
+	}
+	if framing.HasToken(connection, "close") {
+		return false
+	}
+	if r.StatusLine.HTTPVersion == "1.0" {
+		return framing.HasToken(connection, "keep-alive")
+	}
+	return true
+}
+
+func parseStatusLine(data []byte) (*StatusLine, int, error) {
+	idx := bytes.Index(data, CRLF)
+	if idx == -1 {
+		return nil, 0, nil
+	}
+
+	statusLine, err := statusLineFromString(string(data[:idx]))
+	if err != nil {
+		return nil, idx + len(CRLF), err
+	}
+
+	return statusLine, idx + len(CRLF), nil
+}
+
+func statusLineFromString(str string) (*StatusLine, error) {
+	versionPart, rest, ok := strings.Cut(str, " ")
+	if !ok {
+		return nil, ErrMalformedStatusLine
+	}
+	// The SP before an empty reason phrase is sometimes left out.
+	code, reason, _ := strings.Cut(rest, " ")
+
+	versionParts := strings.Split(versionPart, "/")
+	if len(versionParts) != 2 || versionParts[0] != "HTTP" {
+		return nil, ErrMalformedStatusLine
+	}
+	version := versionParts[1]
+	if version != "1.1" && version != "1.0" {
+		return nil, ErrUnsupportedHTTPVersion
+	}
+
+	if len(code) != 3 {
+		return nil, ErrMalformedStatusCode
+	}
+	for _, char := range code {
+		if char < '0' || char > '9' {
+			return nil, ErrMalformedStatusCode
+		}
+	}
+	statusCode, err := strconv.Atoi(code)
+	if err != nil || statusCode < 100 {
+		return nil, ErrMalformedStatusCode
+	}
+
+	for i := 0; i < len(reason); i++ {
+		if (reason[i] < 0x20 && reason[i] != '\t') || reason[i] == 0x7f {
+			return nil, ErrMalformedReasonPhrase
+		}
+	}
+
+	return &StatusLine{
+		HTTPVersion:  version,
+		StatusCode:   StatusCode(statusCode),
+		ReasonPhrase: reason,
+	}, nil
+}
+
+func (r *Response) parse(data []byte) (int, error) {
+	totalParsedBytes := 0
+	for r.state != responseStateDone {
+		state := r.state
+		n, err := r.parseSingle(data[totalParsedBytes:])
+		if err != nil {
+			return 0, err
+		}
+		totalParsedBytes += n
+		if n == 0 && r.state == state {
+			break
+		}
+	}
+
+	return totalParsedBytes, nil
+}
+
+func (r *Response) parseSingle(data []byte) (int, error) {
+	switch r.state {
+	case responseStateInit:
+		sl, n, err := parseStatusLine(data)
+		lineLength := n - len(CRLF)
+		if n == 0 {
+			lineLength = len(data)
+		}
+		if framing.Exceeds(lineLength, r.limits.MaxStatusLineLength) {
+			return 0, ErrStatusLineTooLong
+		}
+		if err != nil {
+			return 0, err
+		}
+		if n == 0 {
+			return 0, nil
+		}
+		r.StatusLine = *sl
+		r.state = responseStateParsingHeaders
+		return n, nil
+	case responseStateParsingHeaders:
+		n, done, err := r.Headers.Parse(data)
+		if err != nil {
+			return 0, err
+		}
+		err = r.countHeaderBytes(n, data)
+		if err != nil {
+			return 0, err
+		}
+		if done {
+			err = r.startBody()
+			if err != nil {
+				return 0, err
+			}
+		}
+		return n, nil
+	case responseStateParsingBody:
+		n := int(min(int64(len(data)), r.contentLength-r.bodyBytes))
+		r.appendBody(data[:n])
+		if r.bodyBytes == r.contentLength {
+			r.state = responseStateDone
+		}
+		return n, nil
+	case responseStateParsingChunked:
+		inTrailers := r.chunked.InTrailers()
+		n, chunk, err := r.chunked.Decode(data)
+		if err != nil {
+			return 0, err
+		}
+		if inTrailers {
+			err = r.countHeaderBytes(n, data)
+			if err != nil {
+				return 0, err
+			}
+		}
+		if framing.Exceeds(r.bodyBytes+int64(len(chunk)), r.limits.MaxBodyBytes) {
+			return 0, ErrBodyTooLarge
+		}
+		r.appendBody(chunk)
+		if r.chunked.InTrailers() && !inTrailers {
+			r.headerBytes.Reset()
+		}
+		if r.chunked.Done() {
+			r.Trailers = r.chunked.Trailers
+			r.state = responseStateDone
+		}
+		return n, nil
+	case responseStateParsingUntilEOF:
+		if framing.Exceeds(r.bodyBytes+int64(len(data)), r.limits.MaxBodyBytes) {
+			return 0, ErrBodyTooLarge
+		}
+		r.appendBody(data)
+		return len(data), nil
+	case responseStateDone:
+		return 0, ErrReadingDataInDoneState
+	default:
+		return 0, ErrUnknownResponseState
+	}
+}
+
+// startBody picks the body framing once the header section is complete,
+// following RFC 9112 section 6.3.
+func (r *Response) startBody() error {
+	if !r.hasBody() {
+		r.state = responseStateDone
+		return nil
+	}
+
+	te, err := r.Headers.Get("Transfer-Encoding")
+	if err == nil {
+		if chunked.IsChunked(te) {
+			r.chunked = chunked.NewDecoder()
+			r.state = responseStateParsingChunked
+		} else {
+			r.state = responseStateParsingUntilEOF
+		}
+		return nil
+	}
+
+	cl := r.Headers.Values("Content-Length")
+	if len(cl) == 0 {
+		r.state = responseStateParsingUntilEOF
+		return nil
+	}
+	contentLen, err := framing.ParseContentLength(cl)
+	if err != nil {
+		return err
+	}
+	if framing.Exceeds(contentLen, r.limits.MaxBodyBytes) {
+		return ErrBodyTooLarge
+	}
+
+	r.contentLength = contentLen
+	r.state = responseStateParsingBody
+	if contentLen == 0 {
+		r.state = responseStateDone
+	}
+	return nil
+}
+
+// hasBody reports whether the response carries a body at all. Responses to
+// HEAD and responses with a 1xx, 204 or 304 status never do.
+func (r *Response) hasBody() bool {
+	code := r.StatusLine.StatusCode
+	if r.method == "HEAD" {
+		return false
+	}
+	return code >= 200 && code != StatusNoContent && code != StatusNotModified
+}
+
+func (r *Response) bodyUntilEOF() bool {
+	if !r.hasBody() {
+		return false
+	}
+	te, err := r.Headers.Get("Transfer-Encoding")
+	if err == nil {
+		return !chunked.IsChunked(te)
+	}
+	_, err = r.Headers.Get("Content-Length")
+	return err != nil
+}
+
+func (r *Response) appendBody(data []byte) {
+	r.Body = append(r.Body, data...)
+	r.bodyBytes += int64(len(data))
+}
+
+// countHeaderBytes adds a field line to the size of the header or trailer
+// section, as framing.SectionSize.Add does.
+func (r *Response) countHeaderBytes(n int, data []byte) error {
+	if !r.headerBytes.Add(n, data, r.limits.MaxHeaderBytes) {
+		return ErrHeadersTooLarge
+	}
+	return nil
+}
+
+func (r *Response) done() bool {
+	return r.state == responseStateDone
+}
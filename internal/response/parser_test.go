@@ -0,0 +1,197 @@
+package response
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type chunkReader struct {
+	data            string
+	numBytesPerRead int
+	pos             int
+}
+
+// Read reads up to len(p) or numBytesPerRead bytes from the string per call
+// its useful for simulating reading a variable number of bytes per chunk from a network connection
+func (cr *chunkReader) Read(p []byte) (n int, err error) {
+	if cr.pos >= len(cr.data) {
+		return 0, io.EOF
+	}
+	endIndex := min(cr.pos+cr.numBytesPerRead, len(cr.data))
+	n = copy(p, cr.data[cr.pos:endIndex])
+	cr.pos += n
+
+	return n, nil
+}
+
+func TestStatusLineParse(t *testing.T) {
+	// Test: Good status line
+	r, err := ResponseFromReader(&chunkReader{
+		data:            "HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n",
+		numBytesPerRead: 3,
+	}, "GET")
+	require.NoError(t, err)
+	assert.Equal(t, "1.1", r.StatusLine.HTTPVersion)
+	assert.Equal(t, StatusOK, r.StatusLine.StatusCode)
+	assert.Equal(t, "OK", r.StatusLine.ReasonPhrase)
+
+	// Test: Multi-word reason phrase
+	r, err = ResponseFromReader(&chunkReader{
+		data:            "HTTP/1.0 404 Not Found\r\nContent-Length: 0\r\n\r\n",
+		numBytesPerRead: 1,
+	}, "GET")
+	require.NoError(t, err)
+	assert.Equal(t, "1.0", r.StatusLine.HTTPVersion)
+	assert.Equal(t, StatusNotFound, r.StatusLine.StatusCode)
+	assert.Equal(t, "Not Found", r.StatusLine.ReasonPhrase)
+
+	// Test: Empty reason phrase, with and without the trailing space
+	r, err = ResponseFromReader(&chunkReader{
+		data:            "HTTP/1.1 299 \r\nContent-Length: 0\r\n\r\n",
+		numBytesPerRead: 10,
+	}, "GET")
+	require.NoError(t, err)
+	assert.Equal(t, StatusCode(299), r.StatusLine.StatusCode)
+	assert.Equal(t, "", r.StatusLine.ReasonPhrase)
+
+	r, err = ResponseFromReader(&chunkReader{
+		data:            "HTTP/1.1 204\r\n\r\n",
+		numBytesPerRead: 10,
+	}, "GET")
+	require.NoError(t, err)
+	assert.Equal(t, StatusNoContent, r.StatusLine.StatusCode)
+
+	// Test: Invalid status codes
+	for _, line := range []string{"HTTP/1.1 20 OK", "HTTP/1.1 2000 OK", "HTTP/1.1 +20 OK", "HTTP/1.1 099 OK", "HTTP/1.1 abc OK"} {
+		_, err = ResponseFromReader(&chunkReader{data: line + "\r\n\r\n", numBytesPerRead: 10}, "GET")
+		require.ErrorIs(t, err, ErrMalformedStatusCode, line)
+	}
+
+	// Test: Unsupported version
+	_, err = ResponseFromReader(&chunkReader{
+		data:            "HTTP/2.0 200 OK\r\n\r\n",
+		numBytesPerRead: 10,
+	}, "GET")
+	require.ErrorIs(t, err, ErrUnsupportedHTTPVersion)
+
+	// Test: Malformed status line
+	_, err = ResponseFromReader(&chunkReader{
+		data:            "200 OK HTTP/1.1\r\n\r\n",
+		numBytesPerRead: 10,
+	}, "GET")
+	require.ErrorIs(t, err, ErrMalformedStatusLine)
+}
+
+func TestResponseBodyParse(t *testing.T) {
+	// Test: Content-Length body
+	r, err := ResponseFromReader(&chunkReader{
+		data:            "HTTP/1.1 200 OK\r\nContent-Length: 13\r\n\r\nhello world!\n",
+		numBytesPerRead: 3,
+	}, "GET")
+	require.NoError(t, err)
+	assert.Equal(t, "hello world!\n", string(r.Body))
+	assert.True(t, r.KeepAlive())
+
+	// Test: Chunked body with trailers
+	r, err = ResponseFromReader(&chunkReader{
+		data: "HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n" +
+			"6\r\nhello \r\n7\r\nworld!\n\r\n0\r\nChecksum: abc\r\n\r\n",
+		numBytesPerRead: 4,
+	}, "GET")
+	require.NoError(t, err)
+	assert.Equal(t, "hello world!\n", string(r.Body))
+	checksum, err := r.Trailers.Get("Checksum")
+	require.NoError(t, err)
+	assert.Equal(t, "abc", checksum)
+	assert.True(t, r.KeepAlive())
+
+	// Test: Body delimited by the connection closing
+	r, err = ResponseFromReader(&chunkReader{
+		data:            "HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\n\r\nuntil the end",
+		numBytesPerRead: 5,
+	}, "GET")
+	require.NoError(t, err)
+	assert.Equal(t, "until the end", string(r.Body))
+	assert.False(t, r.KeepAlive())
+
+	// Test: Responses without a body
+	for _, tc := range []struct {
+		raw    string
+		method string
+	}{
+		{"HTTP/1.1 200 OK\r\nContent-Length: 13\r\n\r\n", "HEAD"},
+		{"HTTP/1.1 100 Continue\r\n\r\n", "POST"},
+		{"HTTP/1.1 204 No Content\r\n\r\n", "DELETE"},
+		{"HTTP/1.1 304 Not Modified\r\nContent-Length: 13\r\n\r\n", "GET"},
+	} {
+		reader := NewReader(&chunkReader{
+			data:            tc.raw + "HTTP/1.1 200 OK\r\nContent-Length: 4\r\n\r\nnext",
+			numBytesPerRead: 7,
+		})
+		r, err = reader.ReadResponse(tc.method)
+		require.NoError(t, err, tc.raw)
+		assert.Empty(t, r.Body, tc.raw)
+
+		r, err = reader.ReadResponse("GET")
+		require.NoError(t, err, tc.raw)
+		assert.Equal(t, "next", string(r.Body), tc.raw)
+	}
+
+	// Test: Truncated Content-Length body
+	_, err = ResponseFromReader(&chunkReader{
+		data:            "HTTP/1.1 200 OK\r\nContent-Length: 20\r\n\r\npartial",
+		numBytesPerRead: 5,
+	}, "GET")
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	// Test: Repeated identical Content-Length values
+	r, err = ResponseFromReader(&chunkReader{
+		data:            "HTTP/1.1 200 OK\r\nContent-Length: 5, 5\r\nContent-Length: 5\r\n\r\nhello",
+		numBytesPerRead: 5,
+	}, "GET")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(r.Body))
+
+	// Test: Signed and conflicting Content-Length values
+	_, err = ResponseFromReader(&chunkReader{
+		data:            "HTTP/1.1 200 OK\r\nContent-Length: +5\r\n\r\nhello",
+		numBytesPerRead: 5,
+	}, "GET")
+	require.ErrorIs(t, err, ErrSignedContentLength)
+	_, err = ResponseFromReader(&chunkReader{
+		data:            "HTTP/1.1 200 OK\r\nContent-Length: 5, 6\r\n\r\nhello",
+		numBytesPerRead: 5,
+	}, "GET")
+	require.ErrorIs(t, err, ErrConflictingContentLength)
+
+	// Test: Clean close before a response
+	_, err = ResponseFromReader(&chunkReader{}, "GET")
+	require.ErrorIs(t, err, io.EOF)
+
+	// Test: Body above the limit
+	reader := NewReader(&chunkReader{
+		data:            "HTTP/1.1 200 OK\r\n\r\n" + "more than ten bytes",
+		numBytesPerRead: 5,
+	})
+	reader.Limits.MaxBodyBytes = 10
+	_, err = reader.ReadResponse("GET")
+	require.ErrorIs(t, err, ErrBodyTooLarge)
+
+	// Test: Connection: close and HTTP/1.0
+	r, err = ResponseFromReader(&chunkReader{
+		data:            "HTTP/1.1 200 OK\r\nConnection: close\r\nContent-Length: 0\r\n\r\n",
+		numBytesPerRead: 5,
+	}, "GET")
+	require.NoError(t, err)
+	assert.False(t, r.KeepAlive())
+
+	r, err = ResponseFromReader(&chunkReader{
+		data:            "HTTP/1.0 200 OK\r\nContent-Length: 0\r\n\r\n",
+		numBytesPerRead: 5,
+	}, "GET")
+	require.NoError(t, err)
+	assert.False(t, r.KeepAlive())
+}
@@ -0,0 +1,230 @@
+// Package response writes HTTP/1.1 responses to a stream and parses them back.
+//
+// A Writer emits the status line, then the header fields, then the body, and
+// rejects calls made out of that order so a handler cannot produce a
+// malformed response by accident. ResponseFromReader mirrors the request
+// parser: it reads the status line and headers incrementally, then a body
+// framed by Content-Length, the chunked transfer coding or the connection
+// closing.
+package response
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/Dawid-Klos/httpfromtcp/internal/framing"
+	"github.com/Dawid-Klos/httpfromtcp/internal/headers"
+)
+
+type StatusCode int
+
+const (
+	StatusContinue           StatusCode = 100
+	StatusSwitchingProtocols StatusCode = 101
+
+	StatusOK                StatusCode = 200
+	StatusCreated           StatusCode = 201
+	StatusAccepted          StatusCode = 202
+	StatusNoContent         StatusCode = 204
+	StatusPartialContent    StatusCode = 206
+	StatusMovedPermanently  StatusCode = 301
+	StatusFound             StatusCode = 302
+	StatusSeeOther          StatusCode = 303
+	StatusNotModified       StatusCode = 304
+	StatusTemporaryRedirect StatusCode = 307
+	StatusPermanentRedirect StatusCode = 308
+
+	StatusBadRequest                  StatusCode = 400
+	StatusUnauthorized                StatusCode = 401
+	StatusForbidden                   StatusCode = 403
+	StatusNotFound                    StatusCode = 404
+	StatusMethodNotAllowed            StatusCode = 405
+	StatusRequestTimeout              StatusCode = 408
+	StatusConflict                    StatusCode = 409
+	StatusLengthRequired              StatusCode = 411
+	StatusContentTooLarge             StatusCode = 413
+	StatusURITooLong                  StatusCode = 414
+	StatusUnsupportedMediaType        StatusCode = 415
+	StatusExpectationFailed           StatusCode = 417
+	StatusTooManyRequests             StatusCode = 429
+	StatusRequestHeaderFieldsTooLarge StatusCode = 431
+
+	StatusInternalServerError     StatusCode = 500
+	StatusNotImplemented          StatusCode = 501
+	StatusBadGateway              StatusCode = 502
+	StatusServiceUnavailable      StatusCode = 503
+	StatusGatewayTimeout          StatusCode = 504
+	StatusHTTPVersionNotSupported StatusCode = 505
+)
+
+var reasonPhrases = map[StatusCode]string{
+	StatusContinue:           "Continue",
+	StatusSwitchingProtocols: "Switching Protocols",
+
+	StatusOK:                "OK",
+	StatusCreated:           "Created",
+	StatusAccepted:          "Accepted",
+	StatusNoContent:         "No Content",
+	StatusPartialContent:    "Partial Content",
+	StatusMovedPermanently:  "Moved Permanently",
+	StatusFound:             "Found",
+	StatusSeeOther:          "See Other",
+	StatusNotModified:       "Not Modified",
+	StatusTemporaryRedirect: "Temporary Redirect",
+	StatusPermanentRedirect: "Permanent Redirect",
+
+	StatusBadRequest:                  "Bad Request",
+	StatusUnauthorized:                "Unauthorized",
+	StatusForbidden:                   "Forbidden",
+	StatusNotFound:                    "Not Found",
+	StatusMethodNotAllowed:            "Method Not Allowed",
+	StatusRequestTimeout:              "Request Timeout",
+	StatusConflict:                    "Conflict",
+	StatusLengthRequired:              "Length Required",
+	StatusContentTooLarge:             "Content Too Large",
+	StatusURITooLong:                  "URI Too Long",
+	StatusUnsupportedMediaType:        "Unsupported Media Type",
+	StatusExpectationFailed:           "Expectation Failed",
+	StatusTooManyRequests:             "Too Many Requests",
+	StatusRequestHeaderFieldsTooLarge: "Request Header Fields Too Large",
+
+	StatusInternalServerError:     "Internal Server Error",
+	StatusNotImplemented:          "Not Implemented",
+	StatusBadGateway:              "Bad Gateway",
+	StatusServiceUnavailable:      "Service Unavailable",
+	StatusGatewayTimeout:          "Gateway Timeout",
+	StatusHTTPVersionNotSupported: "HTTP Version Not Supported",
+}
+
+// ReasonPhrase returns the standard reason phrase for the status code, or an
+// empty string if the code is unknown.
+func ReasonPhrase(statusCode StatusCode) string {
+	return reasonPhrases[statusCode]
+}
+
+type writerState string
+
+const (
+	writerStateStatusLine writerState = "status line"
+	writerStateHeaders    writerState = "headers"
+	writerStateBody       writerState = "body"
+)
+
+var ErrInvalidStatusCode = errors.New("status code must have three digits")
+var ErrStatusLineWritten = errors.New("status line already written")
+var ErrStatusLineNotWritten = errors.New("status line must be written before headers")
+var ErrHeadersWritten = errors.New("headers already written")
+var ErrHeadersNotWritten = errors.New("headers must be written before body")
+
+type Writer struct {
+	w         io.Writer
+	state     writerState
+	keepAlive bool
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		w:     w,
+		state: writerStateStatusLine,
+	}
+}
+
+// GetDefaultHeaders returns the headers sent with a plain text body of the
+// given length.
+func GetDefaultHeaders(contentLen int) headers.Headers {
+	h := headers.NewHeaders()
+	h.Set("Content-Length", strconv.Itoa(contentLen))
+	h.Set("Content-Type", "text/plain")
+	return h
+}
+
+// KeepAlive reports whether the connection can carry another response after
+// this one. It is false until the headers are written, and when they either
+// ask for the connection to be closed or leave the body length undelimited.
+func (w *Writer) KeepAlive() bool {
+	return w.keepAlive
+}
+
+func (w *Writer) WriteStatusLine(statusCode StatusCode) error {
+	if w.state != writerStateStatusLine {
+		return ErrStatusLineWritten
+	}
+	if statusCode < 100 || statusCode > 999 {
+		return ErrInvalidStatusCode
+	}
+
+	_, err := fmt.Fprintf(w.w, "HTTP/1.1 %d %s\r\n", statusCode, ReasonPhrase(statusCode))
+	if err != nil {
+		return err
+	}
+	w.state = writerStateHeaders
+	return nil
+}
+
+// WriteContinue writes an interim 100 (Continue) response, telling a client
+// that sent "Expect: 100-continue" to go ahead with the body. It must come
+// before the final status line, which is still to be written afterwards.
+func (w *Writer) WriteContinue() error {
+	if w.state != writerStateStatusLine {
+		return ErrStatusLineWritten
+	}
+	_, err := fmt.Fprintf(w.w, "HTTP/1.1 %d %s\r\n\r\n", StatusContinue, ReasonPhrase(StatusContinue))
+	return err
+}
+
+// WriteHeaders writes the header section, including the blank line that
+// terminates it. Fields are written in order, one line per value. A field
+// that fails headers.Validate, such as a value containing CR or LF, is
+// rejected before anything is written.
+func (w *Writer) WriteHeaders(h headers.Headers) error {
+	switch w.state {
+	case writerStateStatusLine:
+		return ErrStatusLineNotWritten
+	case writerStateBody:
+		return ErrHeadersWritten
+	}
+	err := h.Validate()
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, 0, 256)
+	for name, value := range h.All() {
+		buf = append(buf, name...)
+		buf = append(buf, ": "...)
+		buf = append(buf, value...)
+		buf = append(buf, "\r\n"...)
+	}
+	buf = append(buf, "\r\n"...)
+
+	_, err = w.w.Write(buf)
+	if err != nil {
+		return err
+	}
+	w.state = writerStateBody
+	w.keepAlive = keepAlive(h)
+	return nil
+}
+
+func keepAlive(h headers.Headers) bool {
+	connection, err := h.Get("Connection")
+	if err == nil && framing.HasToken(connection, "close") {
+		return false
+	}
+
+	_, err = h.Get("Content-Length")
+	if err == nil {
+		return true
+	}
+	_, err = h.Get("Transfer-Encoding")
+	return err == nil
+}
+
+func (w *Writer) WriteBody(p []byte) (int, error) {
+	if w.state != writerStateBody {
+		return 0, ErrHeadersNotWritten
+	}
+	return w.w.Write(p)
+}
@@ -0,0 +1,132 @@
+package response
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Dawid-Klos/httpfromtcp/internal/headers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriter(t *testing.T) {
+	// Test: Full response
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf)
+	require.NoError(t, w.WriteStatusLine(StatusOK))
+	require.NoError(t, w.WriteHeaders(GetDefaultHeaders(13)))
+	n, err := w.WriteBody([]byte("hello world!\n"))
+	require.NoError(t, err)
+	assert.Equal(t, 13, n)
+	assert.True(t, w.KeepAlive())
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n"+
+		"Content-Length: 13\r\n"+
+		"Content-Type: text/plain\r\n"+
+		"\r\n"+
+		"hello world!\n", buf.String())
+
+	// Test: Reason phrases for standard codes
+	buf = &bytes.Buffer{}
+	w = NewWriter(buf)
+	require.NoError(t, w.WriteStatusLine(StatusRequestHeaderFieldsTooLarge))
+	assert.Equal(t, "HTTP/1.1 431 Request Header Fields Too Large\r\n", buf.String())
+
+	// Test: Unknown status code has an empty reason phrase
+	buf = &bytes.Buffer{}
+	w = NewWriter(buf)
+	require.NoError(t, w.WriteStatusLine(299))
+	assert.Equal(t, "HTTP/1.1 299 \r\n", buf.String())
+
+	// Test: Fields keep their order, casing and repeated values
+	buf = &bytes.Buffer{}
+	w = NewWriter(buf)
+	h := headers.NewHeaders()
+	h.Add("Set-Cookie", "a=1")
+	h.Add("content-length", "0")
+	h.Add("Set-Cookie", "b=2")
+	require.NoError(t, w.WriteStatusLine(StatusOK))
+	require.NoError(t, w.WriteHeaders(h))
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n"+
+		"Set-Cookie: a=1\r\n"+
+		"content-length: 0\r\n"+
+		"Set-Cookie: b=2\r\n"+
+		"\r\n", buf.String())
+
+	// Test: Empty headers
+	buf = &bytes.Buffer{}
+	w = NewWriter(buf)
+	require.NoError(t, w.WriteStatusLine(StatusNoContent))
+	require.NoError(t, w.WriteHeaders(headers.NewHeaders()))
+	assert.Equal(t, "HTTP/1.1 204 No Content\r\n\r\n", buf.String())
+
+	// Test: Connection: close ends keep-alive
+	w = NewWriter(&bytes.Buffer{})
+	h = GetDefaultHeaders(0)
+	h.Set("Connection", "close")
+	require.NoError(t, w.WriteStatusLine(StatusOK))
+	assert.False(t, w.KeepAlive())
+	require.NoError(t, w.WriteHeaders(h))
+	assert.False(t, w.KeepAlive())
+
+	// Test: Body without a length ends keep-alive
+	w = NewWriter(&bytes.Buffer{})
+	require.NoError(t, w.WriteStatusLine(StatusOK))
+	h = headers.NewHeaders()
+	h.Set("Content-Type", "text/plain")
+	require.NoError(t, w.WriteHeaders(h))
+	assert.False(t, w.KeepAlive())
+
+	// Test: Invalid status code
+	w = NewWriter(&bytes.Buffer{})
+	require.ErrorIs(t, w.WriteStatusLine(42), ErrInvalidStatusCode)
+
+	// Test: 100 Continue precedes the final response
+	buf = &bytes.Buffer{}
+	w = NewWriter(buf)
+	require.NoError(t, w.WriteContinue())
+	require.NoError(t, w.WriteStatusLine(StatusNoContent))
+	assert.Equal(t, "HTTP/1.1 100 Continue\r\n\r\nHTTP/1.1 204 No Content\r\n", buf.String())
+	require.ErrorIs(t, w.WriteContinue(), ErrStatusLineWritten)
+}
+
+func TestWriterOrdering(t *testing.T) {
+	// Test: Headers before status line
+	w := NewWriter(&bytes.Buffer{})
+	require.ErrorIs(t, w.WriteHeaders(GetDefaultHeaders(0)), ErrStatusLineNotWritten)
+
+	// Test: Body before status line
+	w = NewWriter(&bytes.Buffer{})
+	_, err := w.WriteBody([]byte("hello"))
+	require.ErrorIs(t, err, ErrHeadersNotWritten)
+
+	// Test: Body before headers
+	w = NewWriter(&bytes.Buffer{})
+	require.NoError(t, w.WriteStatusLine(StatusOK))
+	_, err = w.WriteBody([]byte("hello"))
+	require.ErrorIs(t, err, ErrHeadersNotWritten)
+
+	// Test: Status line written twice
+	require.ErrorIs(t, w.WriteStatusLine(StatusOK), ErrStatusLineWritten)
+
+	// Test: Headers written twice
+	require.NoError(t, w.WriteHeaders(GetDefaultHeaders(0)))
+	require.ErrorIs(t, w.WriteHeaders(GetDefaultHeaders(0)), ErrHeadersWritten)
+
+	// Test: Status line after body started
+	require.ErrorIs(t, w.WriteStatusLine(StatusOK), ErrStatusLineWritten)
+
+	// Test: Fields that would split the response are not written
+	buf := &bytes.Buffer{}
+	w = NewWriter(buf)
+	require.NoError(t, w.WriteStatusLine(StatusFound))
+	h := GetDefaultHeaders(0)
+	h.Set("Location", "/\r\nSet-Cookie: a=1")
+	require.ErrorIs(t, w.WriteHeaders(h), headers.ErrMalformedFieldValue)
+	h = GetDefaultHeaders(0)
+	h.Add("X-Bad\r\nSet-Cookie", "a=1")
+	require.ErrorIs(t, w.WriteHeaders(h), headers.ErrMalformedFieldName)
+	assert.Equal(t, "HTTP/1.1 302 Found\r\n", buf.String())
+
+	// Test: Valid headers can still follow a rejected set
+	require.NoError(t, w.WriteHeaders(GetDefaultHeaders(0)))
+}
@@ -0,0 +1,271 @@
+// Package server serves HTTP/1.1 requests over TCP.
+//
+// Every accepted connection is handled in its own goroutine: the request is
+// read with a request.Reader and handed to a Handler, which writes the
+// response through a response.Writer. Connections are kept open for further
+// requests until either side asks for them to be closed.
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Dawid-Klos/httpfromtcp/internal/request"
+	"github.com/Dawid-Klos/httpfromtcp/internal/response"
+)
+
+var errInvalidHost = errors.New("missing or repeated Host header")
+
+const (
+	drainTimeout  = 500 * time.Millisecond
+	maxDrainBytes = 256 << 10
+)
+
+// Failed accepts are retried after a delay that doubles from minAcceptDelay
+// up to maxAcceptDelay, as net/http does.
+const (
+	minAcceptDelay = 5 * time.Millisecond
+	maxAcceptDelay = time.Second
+)
+
+// Handler responds to a single request by writing the status line, headers
+// and body to w.
+type Handler func(w *response.Writer, req *request.Request)
+
+type Server struct {
+	listener net.Listener
+	handler  Handler
+	closed   atomic.Bool
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+	wg    sync.WaitGroup
+}
+
+func Serve(port int, handler Handler) (*Server, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		listener: listener,
+		handler:  handler,
+		conns:    make(map[net.Conn]struct{}),
+	}
+	s.wg.Add(1)
+	go s.listen()
+
+	return s, nil
+}
+
+// Addr returns the address the server is listening on, which is useful when
+// it was started on port 0.
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Close stops accepting connections, closes the ones in flight and waits for
+// their goroutines to return.
+func (s *Server) Close() error {
+	if s.closed.Swap(true) {
+		return nil
+	}
+	err := s.listener.Close()
+
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) listen() {
+	defer s.wg.Done()
+	var delay time.Duration
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if s.closed.Load() || errors.Is(err, net.ErrClosed) {
+				return
+			}
+			// Errors such as running out of file descriptors tend to pass,
+			// so back off instead of spinning on them.
+			delay = min(max(delay*2, minAcceptDelay), maxAcceptDelay)
+			log.Printf("error accepting connection: %v; retrying in %v", err, delay)
+			time.Sleep(delay)
+			continue
+		}
+		delay = 0
+
+		if !s.track(conn) {
+			conn.Close()
+			return
+		}
+		s.wg.Add(1)
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) track(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed.Load() {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	return true
+}
+
+func (s *Server) untrack(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conns, conn)
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer s.wg.Done()
+	defer s.untrack(conn)
+	defer conn.Close()
+
+	reader := request.NewReader(conn)
+	defer reader.Release()
+	reader.StreamBody = true
+	for {
+		w := response.NewWriter(conn)
+		req, err := reader.ReadRequest()
+		if err == nil && !validHost(req) {
+			err = errInvalidHost
+		}
+
+		var cr *continueReader
+		if err == nil && req.ExpectsContinue() {
+			// The body is only sent once the handler starts reading it, so
+			// a handler can reject the request based on its headers alone.
+			cr = &continueReader{ReadCloser: req.BodyReader, w: w}
+			req.BodyReader = cr
+		} else if err == nil {
+			err = bufferBody(req)
+		}
+
+		if err != nil {
+			if s.closed.Load() || errors.Is(err, io.EOF) {
+				return
+			}
+			writeError(w, statusForError(err), err.Error())
+			closeWriteAndDrain(conn)
+			return
+		}
+
+		s.handler(w, req)
+		if cr != nil && !cr.sent {
+			// The client may or may not send the body it was holding back,
+			// so the connection cannot be reused.
+			closeWriteAndDrain(conn)
+			return
+		}
+		if !req.KeepAlive() || !w.KeepAlive() {
+			return
+		}
+	}
+}
+
+// bufferBody reads the whole body into Body, so that handlers can use it
+// directly.
+func bufferBody(req *request.Request) error {
+	body, err := io.ReadAll(req.BodyReader)
+	if err != nil {
+		return err
+	}
+	req.Body = body
+	req.BodyReader = io.NopCloser(bytes.NewReader(body))
+	return nil
+}
+
+// continueReader sends a 100 (Continue) response before the first read of a
+// body the client is holding back.
+type continueReader struct {
+	io.ReadCloser
+	w    *response.Writer
+	sent bool
+}
+
+func (c *continueReader) Read(p []byte) (int, error) {
+	if !c.sent {
+		c.sent = true
+		err := c.w.WriteContinue()
+		// Once the final status line is out it is too late for a 100; the
+		// client sends the body eventually anyway.
+		if err != nil && !errors.Is(err, response.ErrStatusLineWritten) {
+			return 0, err
+		}
+	}
+	return c.ReadCloser.Read(p)
+}
+
+// closeWriteAndDrain signals the end of the response and discards what the
+// client is still sending for a short while. Closing a socket with unread data
+// resets the connection, which can destroy the error response in flight.
+func closeWriteAndDrain(conn net.Conn) {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return
+	}
+	err := tcpConn.CloseWrite()
+	if err != nil {
+		return
+	}
+	err = tcpConn.SetReadDeadline(time.Now().Add(drainTimeout))
+	if err != nil {
+		return
+	}
+	io.Copy(io.Discard, io.LimitReader(tcpConn, maxDrainBytes))
+}
+
+// statusForError picks the status code used to reject a request that could
+// not be read.
+func statusForError(err error) response.StatusCode {
+	var parseErr *request.ParseError
+	if errors.As(err, &parseErr) {
+		return response.StatusCode(parseErr.StatusCode)
+	}
+	return response.StatusBadRequest
+}
+
+// validHost reports whether req carries the single Host field that RFC 9112
+// section 3.2 requires of HTTP/1.1 requests. HTTP/1.0 clients may leave it
+// out, but more than one is rejected whatever the version.
+func validHost(req *request.Request) bool {
+	hosts := len(req.Headers.Values("Host"))
+	if req.RequestLine.Version == request.HTTP10 {
+		return hosts <= 1
+	}
+	return hosts == 1
+}
+
+func writeError(w *response.Writer, statusCode response.StatusCode, message string) {
+	body := []byte(message + "\n")
+	h := response.GetDefaultHeaders(len(body))
+	h.Set("Connection", "close")
+
+	err := w.WriteStatusLine(statusCode)
+	if err == nil {
+		err = w.WriteHeaders(h)
+	}
+	if err == nil {
+		_, err = w.WriteBody(body)
+	}
+	if err != nil {
+		log.Printf("error writing response: %v", err)
+	}
+}
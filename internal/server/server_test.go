@@ -0,0 +1,205 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Dawid-Klos/httpfromtcp/internal/request"
+	"github.com/Dawid-Klos/httpfromtcp/internal/response"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func roundTrip(t *testing.T, s *Server, raw string) string {
+	t.Helper()
+	conn, err := net.Dial("tcp", s.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = io.WriteString(conn, raw)
+	require.NoError(t, err)
+
+	resp, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	return string(resp)
+}
+
+func TestServe(t *testing.T) {
+	s, err := Serve(0, func(w *response.Writer, req *request.Request) {
+		statusCode := response.StatusOK
+		body := "hello " + req.RequestLine.Method + "\n"
+		if req.RequestLine.Target == "/fail" {
+			statusCode = response.StatusInternalServerError
+			body = "failed\n"
+		}
+		assert.NoError(t, w.WriteStatusLine(statusCode))
+		assert.NoError(t, w.WriteHeaders(response.GetDefaultHeaders(len(body))))
+		_, err := w.WriteBody([]byte(body))
+		assert.NoError(t, err)
+	})
+	require.NoError(t, err)
+	defer s.Close()
+
+	// Test: Handler writes the response
+	resp := roundTrip(t, s, "GET / HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\n\r\n")
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n"+
+		"Content-Length: 10\r\n"+
+		"Content-Type: text/plain\r\n"+
+		"\r\n"+
+		"hello GET\n", resp)
+
+	// Test: Handler writes an error status
+	resp = roundTrip(t, s, "GET /fail HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\n\r\n")
+	assert.Equal(t, "HTTP/1.1 500 Internal Server Error\r\n"+
+		"Content-Length: 7\r\n"+
+		"Content-Type: text/plain\r\n"+
+		"\r\n"+
+		"failed\n", resp)
+
+	// Test: Malformed request is answered with 400
+	resp = roundTrip(t, s, "GET / HTTP/2\r\nHost: localhost\r\n\r\n")
+	assert.Contains(t, resp, "HTTP/1.1 400 Bad Request\r\n")
+
+	// Test: Unsupported major version is answered with 505
+	resp = roundTrip(t, s, "GET / HTTP/2.0\r\nHost: localhost\r\n\r\n")
+	assert.Contains(t, resp, "HTTP/1.1 505 HTTP Version Not Supported\r\n")
+
+	// Test: Unknown transfer coding is answered with 501
+	resp = roundTrip(t, s, "POST / HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: gzip, chunked\r\n\r\n0\r\n\r\n")
+	assert.Contains(t, resp, "HTTP/1.1 501 Not Implemented\r\n")
+
+	// Test: HTTP/1.1 request without Host is answered with 400
+	resp = roundTrip(t, s, "GET / HTTP/1.1\r\n\r\n")
+	assert.Contains(t, resp, "HTTP/1.1 400 Bad Request\r\n")
+
+	// Test: HTTP/1.0 request without Host is served and the connection closed
+	resp = roundTrip(t, s, "GET / HTTP/1.0\r\n\r\n")
+	assert.Contains(t, resp, "HTTP/1.1 200 OK\r\n")
+
+	// Test: Repeated Host is answered with 400 whatever the version
+	resp = roundTrip(t, s, "GET / HTTP/1.1\r\nHost: localhost\r\nHost: evil.example\r\n\r\n")
+	assert.Contains(t, resp, "HTTP/1.1 400 Bad Request\r\n")
+	resp = roundTrip(t, s, "GET / HTTP/1.0\r\nHost: localhost\r\nhost: localhost\r\n\r\n")
+	assert.Contains(t, resp, "HTTP/1.1 400 Bad Request\r\n")
+
+	// Test: Oversized header section is answered with 431
+	resp = roundTrip(t, s, "GET / HTTP/1.1\r\nHost: localhost\r\nX-Long: "+strings.Repeat("a", 70<<10)+"\r\n\r\n")
+	assert.Contains(t, resp, "HTTP/1.1 431 Request Header Fields Too Large\r\n")
+
+	// Test: Pipelined requests share a connection until Connection: close
+	resp = roundTrip(t, s, "GET /one HTTP/1.1\r\nHost: localhost\r\n\r\n"+
+		"POST /two HTTP/1.1\r\nHost: localhost\r\nContent-Length: 2\r\n\r\nhi"+
+		"GET /three HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\n\r\n"+
+		"GET /ignored HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	assert.Equal(t, 3, strings.Count(resp, "HTTP/1.1 200 OK\r\n"))
+	assert.Contains(t, resp, "hello POST\n")
+}
+
+func TestExpectContinue(t *testing.T) {
+	s, err := Serve(0, func(w *response.Writer, req *request.Request) {
+		statusCode := response.StatusOK
+		body := "rejected\n"
+		if req.RequestLine.Target == "/reject" {
+			statusCode = response.StatusContentTooLarge
+		} else {
+			received, err := io.ReadAll(req.BodyReader)
+			assert.NoError(t, err)
+			body = "got " + string(received) + "\n"
+		}
+		assert.NoError(t, w.WriteStatusLine(statusCode))
+		assert.NoError(t, w.WriteHeaders(response.GetDefaultHeaders(len(body))))
+		_, err := w.WriteBody([]byte(body))
+		assert.NoError(t, err)
+	})
+	require.NoError(t, err)
+	defer s.Close()
+
+	// Test: 100 Continue is sent once the handler reads the body
+	conn, err := net.Dial("tcp", s.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = io.WriteString(conn, "POST /upload HTTP/1.1\r\nHost: localhost\r\nContent-Length: 5\r\nExpect: 100-continue\r\n\r\n")
+	require.NoError(t, err)
+	interim := make([]byte, len("HTTP/1.1 100 Continue\r\n\r\n"))
+	_, err = io.ReadFull(conn, interim)
+	require.NoError(t, err)
+	assert.Equal(t, "HTTP/1.1 100 Continue\r\n\r\n", string(interim))
+
+	_, err = io.WriteString(conn, "hello")
+	require.NoError(t, err)
+	_, err = io.WriteString(conn, "GET /next HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\n\r\n")
+	require.NoError(t, err)
+	resp, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(resp), "HTTP/1.1 200 OK\r\n"))
+	assert.Contains(t, string(resp), "got hello\n")
+	assert.Contains(t, string(resp), "got \n")
+
+	// Test: Rejecting on the headers skips the 100 and closes the connection
+	resp2 := roundTrip(t, s, "POST /reject HTTP/1.1\r\nHost: localhost\r\nContent-Length: 5\r\nExpect: 100-continue\r\n\r\n")
+	assert.True(t, strings.HasPrefix(resp2, "HTTP/1.1 413 Content Too Large\r\n"))
+	assert.NotContains(t, resp2, "100 Continue")
+}
+
+func TestClose(t *testing.T) {
+	s, err := Serve(0, func(w *response.Writer, req *request.Request) {})
+	require.NoError(t, err)
+
+	// Test: Close interrupts connections waiting on a request
+	conn, err := net.Dial("tcp", s.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = io.WriteString(conn, "GET / HTTP/1.1\r\n")
+	require.NoError(t, err)
+
+	require.NoError(t, s.Close())
+	_, err = net.Dial("tcp", s.Addr().String())
+	require.Error(t, err)
+
+	// Test: Close is idempotent
+	require.NoError(t, s.Close())
+}
+
+// failingListener fails every Accept until it is closed.
+type failingListener struct {
+	net.Listener
+	accepts atomic.Int32
+	closed  atomic.Bool
+}
+
+func (l *failingListener) Accept() (net.Conn, error) {
+	l.accepts.Add(1)
+	if l.closed.Load() {
+		return nil, net.ErrClosed
+	}
+	return nil, errors.New("too many open files")
+}
+
+func (l *failingListener) Close() error {
+	l.closed.Store(true)
+	return nil
+}
+
+func TestAcceptBackoff(t *testing.T) {
+	// Test: Failing accepts are retried with a growing delay
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	listener := &failingListener{}
+	s := &Server{listener: listener, conns: make(map[net.Conn]struct{})}
+	s.wg.Add(1)
+	go s.listen()
+	time.Sleep(100 * time.Millisecond)
+	require.NoError(t, s.Close())
+
+	// 5, 10, 20 and 40ms of delay fit in 100ms; a busy loop makes thousands
+	// of calls.
+	assert.LessOrEqual(t, listener.accepts.Load(), int32(8))
+	assert.GreaterOrEqual(t, listener.accepts.Load(), int32(2))
+}